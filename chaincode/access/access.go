@@ -0,0 +1,131 @@
+// Package access identifies the client that submitted a transaction and checks
+// the registrar roles the chaincodes share.
+package access
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RegistrarMSPID is the organization operating the land registrar office
+const RegistrarMSPID = "Org1MSP"
+
+// Roles issued to enrolled identities through the "role" certificate attribute
+const (
+	RoleBuyer    = "BUYER"
+	RoleSeller   = "SELLER"
+	RoleVerifier = "VERIFIER"
+	RoleAdmin    = "ADMIN"
+)
+
+// identitySeparator joins an MSP ID and an enrollment ID into a user ID
+const identitySeparator = "::"
+
+// Caller describes the client identity that submitted the current transaction
+type Caller struct {
+	ID    string // MSPID::enrollmentID, unique across organizations
+	MSPID string
+	Role  string
+}
+
+// IdentityID qualifies an enrollment ID with its MSP ID. Enrollment IDs are only
+// unique within the CA that issued them, so every user ID stored as an owner,
+// claimant, heir or approver is qualified this way.
+func IdentityID(mspID string, enrollmentID string) string {
+	return mspID + identitySeparator + enrollmentID
+}
+
+// IsIdentityID reports whether a user ID is qualified with an MSP ID
+func IsIdentityID(userID string) bool {
+	mspID, enrollmentID, found := strings.Cut(userID, identitySeparator)
+	return found && mspID != "" && enrollmentID != ""
+}
+
+// ValidateIdentityID rejects user IDs that are not qualified with an MSP ID
+func ValidateIdentityID(userID string) error {
+	if !IsIdentityID(userID) {
+		return fmt.Errorf("user ID %q must be of the form MSPID%senrollmentID", userID, identitySeparator)
+	}
+	return nil
+}
+
+// GetCaller derives the submitting identity from the transaction's client
+// certificate. Cross-chaincode calls run under the submitter's identity.
+func GetCaller(ctx contractapi.TransactionContextInterface) (*Caller, error) {
+	identity := ctx.GetClientIdentity()
+
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller MSP ID: %v", err)
+	}
+
+	// Fabric CA embeds the enrollment ID in every certificate it issues; fall back
+	// to the certificate subject for identities issued by other CAs
+	enrollmentID, found, err := identity.GetAttributeValue("hf.EnrollmentID")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller enrollment ID: %v", err)
+	}
+	if !found {
+		enrollmentID, err = identity.GetID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get caller ID: %v", err)
+		}
+	}
+
+	role, _, err := identity.GetAttributeValue("role")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get caller role: %v", err)
+	}
+
+	return &Caller{
+		ID:    IdentityID(mspID, enrollmentID),
+		MSPID: mspID,
+		Role:  role,
+	}, nil
+}
+
+// HasRole reports whether the caller holds any of the given roles
+func (c *Caller) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if c.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRegistrar reports whether the caller is a verifier or admin of the registrar org
+func (c *Caller) IsRegistrar() bool {
+	return c.MSPID == RegistrarMSPID && c.HasRole(RoleVerifier, RoleAdmin)
+}
+
+// IsRegistrarAdmin reports whether the caller is an admin of the registrar org
+func (c *Caller) IsRegistrarAdmin() bool {
+	return c.MSPID == RegistrarMSPID && c.HasRole(RoleAdmin)
+}
+
+// RequireRegistrar returns the caller if it is a registrar verifier or admin
+func RequireRegistrar(ctx contractapi.TransactionContextInterface) (*Caller, error) {
+	caller, err := GetCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.IsRegistrar() {
+		return nil, fmt.Errorf("access denied: caller %s must be a VERIFIER or ADMIN of %s", caller.ID, RegistrarMSPID)
+	}
+	return caller, nil
+}
+
+// RequireRegistrarAdmin returns the caller if it is a registrar admin
+func RequireRegistrarAdmin(ctx contractapi.TransactionContextInterface) (*Caller, error) {
+	caller, err := GetCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.IsRegistrarAdmin() {
+		return nil, fmt.Errorf("access denied: caller %s must be an ADMIN of %s", caller.ID, RegistrarMSPID)
+	}
+	return caller, nil
+}
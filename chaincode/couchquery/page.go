@@ -0,0 +1,14 @@
+package couchquery
+
+import "fmt"
+
+// MaxPageSize bounds a single page so responses stay well under gRPC message limits
+const MaxPageSize = 200
+
+// ValidatePageSize rejects page sizes outside 1..MaxPageSize
+func ValidatePageSize(pageSize int32) error {
+	if pageSize < 1 || pageSize > MaxPageSize {
+		return fmt.Errorf("page size must be between 1 and %d, got %d", MaxPageSize, pageSize)
+	}
+	return nil
+}
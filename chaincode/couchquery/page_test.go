@@ -0,0 +1,23 @@
+package couchquery
+
+import "testing"
+
+func TestValidatePageSize(t *testing.T) {
+	tests := []struct {
+		pageSize int32
+		wantErr  bool
+	}{
+		{-1, true},
+		{0, true},
+		{1, false},
+		{MaxPageSize, false},
+		{MaxPageSize + 1, true},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePageSize(tt.pageSize)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePageSize(%d) = %v, want error %v", tt.pageSize, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+// Package couchquery builds the CouchDB Mango queries the chaincodes run
+// against their state databases and bounds the pages they read them in.
+package couchquery
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Query is a CouchDB Mango query
+type Query struct {
+	Selector map[string]interface{} `json:"selector"`
+	Sort     []map[string]string    `json:"sort,omitempty"`
+}
+
+// Build encodes a CouchDB selector as a query string. Values are marshalled as
+// JSON literals, so caller input can never add operators or fields to the
+// selector.
+func Build(selector map[string]interface{}) (string, error) {
+	return BuildSorted(selector, nil)
+}
+
+// BuildSorted encodes a CouchDB selector with a sort order. Every sorted field
+// must be constrained by the selector and backed by an index.
+func BuildSorted(selector map[string]interface{}, sort []map[string]string) (string, error) {
+	queryJSON, err := json.Marshal(Query{Selector: selector, Sort: sort})
+	if err != nil {
+		return "", fmt.Errorf("failed to build query: %v", err)
+	}
+	return string(queryJSON), nil
+}
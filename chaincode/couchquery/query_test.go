@@ -0,0 +1,75 @@
+package couchquery
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// hostileInputs try to close the string value and add operators or fields
+var hostileInputs = []string{
+	`"`,
+	`\"}`,
+	`{"$gt":""}`,
+	`"},"$or":[{"_id":{"$gt":null}}]`,
+	`.*`,
+	`^(a|b)+$[]{}?\`,
+	"line\nbreak\x00nul",
+}
+
+func TestBuildKeepsValuesLiteral(t *testing.T) {
+	for _, input := range hostileInputs {
+		t.Run(input, func(t *testing.T) {
+			queryString, err := Build(map[string]interface{}{"status": input})
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+
+			var query map[string]interface{}
+			if err := json.Unmarshal([]byte(queryString), &query); err != nil {
+				t.Fatalf("query %s is not valid JSON: %v", queryString, err)
+			}
+			if len(query) != 1 {
+				t.Fatalf("query %s has fields other than the selector", queryString)
+			}
+			selector, ok := query["selector"].(map[string]interface{})
+			if !ok {
+				t.Fatalf("query %s has no selector object", queryString)
+			}
+			if len(selector) != 1 {
+				t.Fatalf("selector %v gained fields", selector)
+			}
+			if value, ok := selector["status"].(string); !ok || value != input {
+				t.Fatalf("status = %#v, want the literal string %q", selector["status"], input)
+			}
+		})
+	}
+}
+
+func TestBuildSorted(t *testing.T) {
+	queryString, err := BuildSorted(map[string]interface{}{"price": map[string]interface{}{"$gte": 0}}, []map[string]string{{"price": "desc"}})
+	if err != nil {
+		t.Fatalf("BuildSorted: %v", err)
+	}
+
+	var query struct {
+		Selector map[string]interface{} `json:"selector"`
+		Sort     []map[string]string    `json:"sort"`
+	}
+	if err := json.Unmarshal([]byte(queryString), &query); err != nil {
+		t.Fatalf("query %s is not valid JSON: %v", queryString, err)
+	}
+	if want := []map[string]string{{"price": "desc"}}; !reflect.DeepEqual(query.Sort, want) {
+		t.Fatalf("sort = %v, want %v", query.Sort, want)
+	}
+}
+
+func TestBuildOmitsEmptySort(t *testing.T) {
+	queryString, err := Build(map[string]interface{}{"status": "VERIFIED"})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if want := `{"selector":{"status":"VERIFIED"}}`; queryString != want {
+		t.Fatalf("query = %s, want %s", queryString, want)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"time"
 
+	"access"
+	"couchquery"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -16,6 +18,9 @@ type EscrowContract struct {
 	contractapi.Contract
 }
 
+// escrowObjectType namespaces escrow keys on the ledger
+const escrowObjectType = "escrow"
+
 // Escrow represents an escrow account
 type Escrow struct {
 	EscrowID        string    `json:"escrowId"`
@@ -29,6 +34,13 @@ type Escrow struct {
 	UpdatedAt       time.Time `json:"updatedAt"`
 }
 
+// PaginatedEscrowResult is one page of escrows plus the bookmark for the next page
+type PaginatedEscrowResult struct {
+	Records      []*Escrow `json:"records"`
+	Bookmark     string    `json:"bookmark"`
+	FetchedCount int32     `json:"fetchedCount"`
+}
+
 // CreateEscrow creates a new escrow account on the ledger
 func (c *EscrowContract) CreateEscrow(ctx contractapi.TransactionContextInterface, escrowID string, propertyID string, buyer string, seller string, amount float64) error {
 	exists, err := c.EscrowExists(ctx, escrowID)
@@ -46,7 +58,7 @@ func (c *EscrowContract) CreateEscrow(ctx contractapi.TransactionContextInterfac
 	}
 	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
 
-	escrow := Escrow{
+	escrow := &Escrow{
 		EscrowID:        escrowID,
 		PropertyID:      propertyID,
 		Buyer:           buyer,
@@ -58,17 +70,17 @@ func (c *EscrowContract) CreateEscrow(ctx contractapi.TransactionContextInterfac
 		UpdatedAt:       timestamp,
 	}
 
-	escrowJSON, err := json.Marshal(escrow)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(escrowID, escrowJSON)
+	return c.putEscrow(ctx, escrow)
 }
 
 // GetEscrow retrieves an escrow account from the ledger
 func (c *EscrowContract) GetEscrow(ctx contractapi.TransactionContextInterface, escrowID string) (*Escrow, error) {
-	escrowJSON, err := ctx.GetStub().GetState(escrowID)
+	key, err := escrowKey(ctx, escrowID)
+	if err != nil {
+		return nil, err
+	}
+
+	escrowJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read escrow: %v", err)
 	}
@@ -107,16 +119,16 @@ func (c *EscrowContract) FundEscrow(ctx contractapi.TransactionContextInterface,
 	escrow.TransactionHash = txHash
 	escrow.UpdatedAt = timestamp
 
-	escrowJSON, err := json.Marshal(escrow)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(escrowID, escrowJSON)
+	return c.putEscrow(ctx, escrow)
 }
 
-// ReleaseEscrow releases funds to the seller
+// ReleaseEscrow releases funds to the seller. It is called by the property
+// contract's SettleSale and is otherwise limited to registrar admins.
 func (c *EscrowContract) ReleaseEscrow(ctx contractapi.TransactionContextInterface, escrowID string, releaseTxHash string) error {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return err
+	}
+
 	escrow, err := c.GetEscrow(ctx, escrowID)
 	if err != nil {
 		return err
@@ -137,12 +149,7 @@ func (c *EscrowContract) ReleaseEscrow(ctx contractapi.TransactionContextInterfa
 	escrow.TransactionHash = releaseTxHash
 	escrow.UpdatedAt = timestamp
 
-	escrowJSON, err := json.Marshal(escrow)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(escrowID, escrowJSON)
+	return c.putEscrow(ctx, escrow)
 }
 
 // CancelEscrow cancels an escrow and refunds the buyer
@@ -167,17 +174,17 @@ func (c *EscrowContract) CancelEscrow(ctx contractapi.TransactionContextInterfac
 	escrow.TransactionHash = refundTxHash
 	escrow.UpdatedAt = timestamp
 
-	escrowJSON, err := json.Marshal(escrow)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(escrowID, escrowJSON)
+	return c.putEscrow(ctx, escrow)
 }
 
 // EscrowExists checks if an escrow exists
 func (c *EscrowContract) EscrowExists(ctx contractapi.TransactionContextInterface, escrowID string) (bool, error) {
-	escrowJSON, err := ctx.GetStub().GetState(escrowID)
+	key, err := escrowKey(ctx, escrowID)
+	if err != nil {
+		return false, err
+	}
+
+	escrowJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return false, fmt.Errorf("failed to read escrow: %v", err)
 	}
@@ -185,9 +192,29 @@ func (c *EscrowContract) EscrowExists(ctx contractapi.TransactionContextInterfac
 	return escrowJSON != nil, nil
 }
 
+// escrowKey returns the composite ledger key of an escrow account
+func escrowKey(ctx contractapi.TransactionContextInterface, escrowID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(escrowObjectType, []string{escrowID})
+}
+
+// putEscrow writes an escrow account under its composite key
+func (c *EscrowContract) putEscrow(ctx contractapi.TransactionContextInterface, escrow *Escrow) error {
+	key, err := escrowKey(ctx, escrow.EscrowID)
+	if err != nil {
+		return err
+	}
+
+	escrowJSON, err := json.Marshal(escrow)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, escrowJSON)
+}
+
 // GetAllEscrows retrieves all escrow accounts
 func (c *EscrowContract) GetAllEscrows(ctx contractapi.TransactionContextInterface) ([]*Escrow, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(escrowObjectType, []string{})
 	if err != nil {
 		return nil, err
 	}
@@ -211,9 +238,119 @@ func (c *EscrowContract) GetAllEscrows(ctx contractapi.TransactionContextInterfa
 	return escrows, nil
 }
 
+// GetEscrowsByProperty retrieves the escrow accounts opened for a property
+func (c *EscrowContract) GetEscrowsByProperty(ctx contractapi.TransactionContextInterface, propertyID string) ([]*Escrow, error) {
+	queryString, err := couchquery.Build(map[string]interface{}{"propertyId": propertyID})
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	escrows := []*Escrow{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var escrow Escrow
+		err = json.Unmarshal(queryResponse.Value, &escrow)
+		if err != nil {
+			return nil, err
+		}
+		escrows = append(escrows, &escrow)
+	}
+
+	return escrows, nil
+}
+
+// GetAllEscrowsWithPagination retrieves one page of escrow accounts
+func (c *EscrowContract) GetAllEscrowsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedEscrowResult, error) {
+	if err := couchquery.ValidatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(escrowObjectType, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	escrows := []*Escrow{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var escrow Escrow
+		err = json.Unmarshal(queryResponse.Value, &escrow)
+		if err != nil {
+			return nil, err
+		}
+		escrows = append(escrows, &escrow)
+	}
+
+	return &PaginatedEscrowResult{
+		Records:      escrows,
+		Bookmark:     metadata.Bookmark,
+		FetchedCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+// MigrateKeys rewrites escrows stored under plain escrow IDs to composite keys and
+// returns how many were moved. Running it again after a migration is a no-op.
+func (c *EscrowContract) MigrateKeys(ctx contractapi.TransactionContextInterface) (int, error) {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return 0, err
+	}
+
+	// GetStateByRange only returns plain keys, never composite ones
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return migrated, err
+		}
+
+		key, err := escrowKey(ctx, queryResponse.Key)
+		if err != nil {
+			return migrated, err
+		}
+
+		err = ctx.GetStub().PutState(key, queryResponse.Value)
+		if err != nil {
+			return migrated, err
+		}
+		err = ctx.GetStub().DelState(queryResponse.Key)
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
 // GetEscrowHistory retrieves the history of an escrow
 func (c *EscrowContract) GetEscrowHistory(ctx contractapi.TransactionContextInterface, escrowID string) ([]map[string]interface{}, error) {
-	resultsIterator, err := ctx.GetStub().GetHistoryForKey(escrowID)
+	key, err := escrowKey(ctx, escrowID)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
 	if err != nil {
 		return nil, err
 	}
@@ -2,7 +2,11 @@ module escrow-contract
 
 go 1.21
 
-require github.com/hyperledger/fabric-contract-api-go v1.2.1
+require (
+	access v0.0.0
+	couchquery v0.0.0
+	github.com/hyperledger/fabric-contract-api-go v1.2.1
+)
 
 require (
 	github.com/go-openapi/jsonpointer v0.19.5 // indirect
@@ -30,3 +34,9 @@ require (
 	google.golang.org/protobuf v1.28.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
+
+// access and couchquery are shared by the chaincodes; vendor them (go mod vendor) before packaging
+replace (
+	access => ../access
+	couchquery => ../couchquery
+)
@@ -3,8 +3,11 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
+	"access"
+	"couchquery"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -13,6 +16,9 @@ type OfferContract struct {
 	contractapi.Contract
 }
 
+// offerObjectType namespaces offer keys on the ledger
+const offerObjectType = "offer"
+
 // Offer represents a property purchase offer
 type Offer struct {
 	OfferID        string    `json:"offerId"`
@@ -28,10 +34,20 @@ type Offer struct {
 	AdminID        string    `json:"adminId"`
 	VerifiedAt     time.Time `json:"verifiedAt"`
 	SepoliaTxHash  string    `json:"sepoliaTxHash"`
+	Tenanted       bool      `json:"tenanted"`       // the property was let when the offer was made
+	GuidelineValue float64   `json:"guidelineValue"` // zero when no guideline rate applied
+	BelowGuideline bool      `json:"belowGuideline"` // the offer was below the property's guideline value
 	CreatedAt      time.Time `json:"createdAt"`
 	UpdatedAt      time.Time `json:"updatedAt"`
 }
 
+// PaginatedOfferResult is one page of offers plus the bookmark for the next page
+type PaginatedOfferResult struct {
+	Records      []*Offer `json:"records"`
+	Bookmark     string   `json:"bookmark"`
+	FetchedCount int32    `json:"fetchedCount"`
+}
+
 // CreateOffer creates a new property purchase offer
 func (c *OfferContract) CreateOffer(ctx contractapi.TransactionContextInterface, offerID string, propertyID string, buyerID string, buyerName string, sellerID string, sellerName string, offerAmount float64, message string) error {
 	exists, err := c.OfferExists(ctx, offerID)
@@ -42,40 +58,61 @@ func (c *OfferContract) CreateOffer(ctx contractapi.TransactionContextInterface,
 		return fmt.Errorf("offer %s already exists", offerID)
 	}
 
-	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	// Mortgaged or liened properties cannot be sold until the encumbrance is
+	// discharged; tenanted ones are sold subject to their leases
+	var eligibility saleEligibility
+	err = invokePropertyContract(ctx, &eligibility, "GetSaleEligibility", propertyID)
 	if err != nil {
-		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+		return err
 	}
-	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
-
-	offer := Offer{
-		OfferID:       offerID,
-		PropertyID:    propertyID,
-		BuyerID:       buyerID,
-		BuyerName:     buyerName,
-		SellerID:      sellerID,
-		SellerName:    sellerName,
-		OfferAmount:   offerAmount,
-		Status:        "PENDING",
-		Message:       message,
-		AdminVerified: false,
-		AdminID:       "",
-		SepoliaTxHash: "",
-		CreatedAt:     timestamp,
-		UpdatedAt:     timestamp,
+	if !eligibility.Eligible {
+		return fmt.Errorf("property %s cannot receive offers: %s", propertyID, eligibility.Reason)
 	}
 
-	offerJSON, err := json.Marshal(offer)
+	// Offers below the guideline value are accepted but flagged for admin review
+	var guideline guidelineCheck
+	err = invokePropertyContract(ctx, &guideline, "CheckGuidelineValue", propertyID, strconv.FormatFloat(offerAmount/weiPerUnit, 'f', -1, 64))
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(offerID, offerJSON)
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	offer := &Offer{
+		OfferID:        offerID,
+		PropertyID:     propertyID,
+		BuyerID:        buyerID,
+		BuyerName:      buyerName,
+		SellerID:       sellerID,
+		SellerName:     sellerName,
+		OfferAmount:    offerAmount,
+		Status:         "PENDING",
+		Message:        message,
+		AdminVerified:  false,
+		AdminID:        "",
+		SepoliaTxHash:  "",
+		Tenanted:       eligibility.Tenanted,
+		GuidelineValue: guideline.GuidelineValue,
+		BelowGuideline: guideline.BelowGuideline,
+		CreatedAt:      timestamp,
+		UpdatedAt:      timestamp,
+	}
+
+	return c.putOffer(ctx, offer)
 }
 
 // GetOffer retrieves an offer from the ledger
 func (c *OfferContract) GetOffer(ctx contractapi.TransactionContextInterface, offerID string) (*Offer, error) {
-	offerJSON, err := ctx.GetStub().GetState(offerID)
+	key, err := offerKey(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	offerJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read offer: %v", err)
 	}
@@ -103,6 +140,33 @@ func (c *OfferContract) AcceptOffer(ctx contractapi.TransactionContextInterface,
 		return fmt.Errorf("offer %s is not in PENDING status", offerID)
 	}
 
+	// Jointly owned properties need every co-owner's consent to this buyer
+	var consented bool
+	err = invokePropertyContract(ctx, &consented, "HasSaleConsent", offer.PropertyID, offer.BuyerID)
+	if err != nil {
+		return err
+	}
+	if !consented {
+		return fmt.Errorf("not all co-owners of property %s have consented to the sale to %s", offer.PropertyID, offer.BuyerID)
+	}
+
+	// The property may have been frozen, encumbered or let since the offer was made
+	var eligibility saleEligibility
+	err = invokePropertyContract(ctx, &eligibility, "GetSaleEligibility", offer.PropertyID)
+	if err != nil {
+		return err
+	}
+	if !eligibility.Eligible {
+		return fmt.Errorf("offer %s cannot be accepted: %s", offerID, eligibility.Reason)
+	}
+	offer.Tenanted = eligibility.Tenanted
+
+	// Accepting puts the property under contract; only the owner may do so
+	err = invokePropertyContract(ctx, nil, "PlaceUnderContract", offerID)
+	if err != nil {
+		return err
+	}
+
 	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
 		return fmt.Errorf("failed to get transaction timestamp: %v", err)
@@ -112,12 +176,7 @@ func (c *OfferContract) AcceptOffer(ctx contractapi.TransactionContextInterface,
 	offer.Status = "ACCEPTED"
 	offer.UpdatedAt = timestamp
 
-	offerJSON, err := json.Marshal(offer)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(offerID, offerJSON)
+	return c.putOffer(ctx, offer)
 }
 
 // RejectOffer - seller rejects the buyer's offer
@@ -140,16 +199,17 @@ func (c *OfferContract) RejectOffer(ctx contractapi.TransactionContextInterface,
 	offer.Status = "REJECTED"
 	offer.UpdatedAt = timestamp
 
-	offerJSON, err := json.Marshal(offer)
+	return c.putOffer(ctx, offer)
+}
+
+// AdminVerifyOffer - admin verifies and approves the transaction. The verifying
+// admin is the registrar admin who submits it.
+func (c *OfferContract) AdminVerifyOffer(ctx contractapi.TransactionContextInterface, offerID string, sepoliaTxHash string) error {
+	caller, err := access.RequireRegistrarAdmin(ctx)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(offerID, offerJSON)
-}
-
-// AdminVerifyOffer - admin verifies and approves the transaction
-func (c *OfferContract) AdminVerifyOffer(ctx contractapi.TransactionContextInterface, offerID string, adminID string, sepoliaTxHash string) error {
 	offer, err := c.GetOffer(ctx, offerID)
 	if err != nil {
 		return err
@@ -167,21 +227,21 @@ func (c *OfferContract) AdminVerifyOffer(ctx contractapi.TransactionContextInter
 
 	offer.Status = "ADMIN_VERIFIED"
 	offer.AdminVerified = true
-	offer.AdminID = adminID
+	offer.AdminID = caller.ID
 	offer.VerifiedAt = timestamp
 	offer.SepoliaTxHash = sepoliaTxHash
 	offer.UpdatedAt = timestamp
 
-	offerJSON, err := json.Marshal(offer)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(offerID, offerJSON)
+	return c.putOffer(ctx, offer)
 }
 
-// CompleteOffer - marks offer as completed after land transfer
+// CompleteOffer - marks offer as completed after land transfer. It is called by
+// the property contract's SettleSale and is otherwise limited to registrar admins.
 func (c *OfferContract) CompleteOffer(ctx contractapi.TransactionContextInterface, offerID string) error {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return err
+	}
+
 	offer, err := c.GetOffer(ctx, offerID)
 	if err != nil {
 		return err
@@ -200,12 +260,7 @@ func (c *OfferContract) CompleteOffer(ctx contractapi.TransactionContextInterfac
 	offer.Status = "COMPLETED"
 	offer.UpdatedAt = timestamp
 
-	offerJSON, err := json.Marshal(offer)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(offerID, offerJSON)
+	return c.putOffer(ctx, offer)
 }
 
 // CancelOffer - cancels an offer
@@ -228,17 +283,17 @@ func (c *OfferContract) CancelOffer(ctx contractapi.TransactionContextInterface,
 	offer.Status = "CANCELLED"
 	offer.UpdatedAt = timestamp
 
-	offerJSON, err := json.Marshal(offer)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(offerID, offerJSON)
+	return c.putOffer(ctx, offer)
 }
 
 // OfferExists checks if an offer exists
 func (c *OfferContract) OfferExists(ctx contractapi.TransactionContextInterface, offerID string) (bool, error) {
-	offerJSON, err := ctx.GetStub().GetState(offerID)
+	key, err := offerKey(ctx, offerID)
+	if err != nil {
+		return false, err
+	}
+
+	offerJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return false, fmt.Errorf("failed to read offer: %v", err)
 	}
@@ -246,36 +301,172 @@ func (c *OfferContract) OfferExists(ctx contractapi.TransactionContextInterface,
 	return offerJSON != nil, nil
 }
 
+// offerKey returns the composite ledger key of an offer
+func offerKey(ctx contractapi.TransactionContextInterface, offerID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(offerObjectType, []string{offerID})
+}
+
+// putOffer writes an offer under its composite key
+func (c *OfferContract) putOffer(ctx contractapi.TransactionContextInterface, offer *Offer) error {
+	key, err := offerKey(ctx, offer.OfferID)
+	if err != nil {
+		return err
+	}
+
+	offerJSON, err := json.Marshal(offer)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, offerJSON)
+}
+
 // GetOffersByProperty retrieves all offers for a property
 func (c *OfferContract) GetOffersByProperty(ctx contractapi.TransactionContextInterface, propertyID string) ([]*Offer, error) {
-	queryString := fmt.Sprintf(`{"selector":{"propertyId":"%s"}}`, propertyID)
+	queryString, err := offersBy("propertyId", propertyID)
+	if err != nil {
+		return nil, err
+	}
 	return c.queryOffers(ctx, queryString)
 }
 
 // GetOffersByBuyer retrieves all offers made by a buyer
 func (c *OfferContract) GetOffersByBuyer(ctx contractapi.TransactionContextInterface, buyerID string) ([]*Offer, error) {
-	queryString := fmt.Sprintf(`{"selector":{"buyerId":"%s"}}`, buyerID)
+	queryString, err := offersBy("buyerId", buyerID)
+	if err != nil {
+		return nil, err
+	}
 	return c.queryOffers(ctx, queryString)
 }
 
 // GetOffersBySeller retrieves all offers received by a seller
 func (c *OfferContract) GetOffersBySeller(ctx contractapi.TransactionContextInterface, sellerID string) ([]*Offer, error) {
-	queryString := fmt.Sprintf(`{"selector":{"sellerId":"%s"}}`, sellerID)
+	queryString, err := offersBy("sellerId", sellerID)
+	if err != nil {
+		return nil, err
+	}
 	return c.queryOffers(ctx, queryString)
 }
 
 // GetOffersByStatus retrieves all offers with a specific status
 func (c *OfferContract) GetOffersByStatus(ctx contractapi.TransactionContextInterface, status string) ([]*Offer, error) {
-	queryString := fmt.Sprintf(`{"selector":{"status":"%s"}}`, status)
+	queryString, err := offersBy("status", status)
+	if err != nil {
+		return nil, err
+	}
 	return c.queryOffers(ctx, queryString)
 }
 
 // GetPendingAdminVerifications retrieves all offers waiting for admin verification
 func (c *OfferContract) GetPendingAdminVerifications(ctx contractapi.TransactionContextInterface) ([]*Offer, error) {
-	queryString := `{"selector":{"status":"ACCEPTED"}}`
+	queryString, err := offersBy("status", "ACCEPTED")
+	if err != nil {
+		return nil, err
+	}
 	return c.queryOffers(ctx, queryString)
 }
 
+// GetBelowGuidelineOffers retrieves the open offers made below the property's
+// guideline value, for admin review
+func (c *OfferContract) GetBelowGuidelineOffers(ctx contractapi.TransactionContextInterface) ([]*Offer, error) {
+	queryString, err := couchquery.Build(map[string]interface{}{
+		"belowGuideline": true,
+		"status":         map[string]interface{}{"$in": []string{"PENDING", "ACCEPTED", "ADMIN_VERIFIED"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.queryOffers(ctx, queryString)
+}
+
+// GetOffersByPropertyWithPagination retrieves one page of offers for a property
+func (c *OfferContract) GetOffersByPropertyWithPagination(ctx contractapi.TransactionContextInterface, propertyID string, pageSize int32, bookmark string) (*PaginatedOfferResult, error) {
+	queryString, err := offersBy("propertyId", propertyID)
+	if err != nil {
+		return nil, err
+	}
+	return c.queryOffersWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// GetOffersByBuyerWithPagination retrieves one page of offers made by a buyer
+func (c *OfferContract) GetOffersByBuyerWithPagination(ctx contractapi.TransactionContextInterface, buyerID string, pageSize int32, bookmark string) (*PaginatedOfferResult, error) {
+	queryString, err := offersBy("buyerId", buyerID)
+	if err != nil {
+		return nil, err
+	}
+	return c.queryOffersWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// GetOffersBySellerWithPagination retrieves one page of offers received by a seller
+func (c *OfferContract) GetOffersBySellerWithPagination(ctx contractapi.TransactionContextInterface, sellerID string, pageSize int32, bookmark string) (*PaginatedOfferResult, error) {
+	queryString, err := offersBy("sellerId", sellerID)
+	if err != nil {
+		return nil, err
+	}
+	return c.queryOffersWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// GetOffersByStatusWithPagination retrieves one page of offers with a specific status
+func (c *OfferContract) GetOffersByStatusWithPagination(ctx contractapi.TransactionContextInterface, status string, pageSize int32, bookmark string) (*PaginatedOfferResult, error) {
+	queryString, err := offersBy("status", status)
+	if err != nil {
+		return nil, err
+	}
+	return c.queryOffersWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// GetPendingAdminVerificationsWithPagination retrieves one page of offers waiting for admin verification
+func (c *OfferContract) GetPendingAdminVerificationsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedOfferResult, error) {
+	queryString, err := offersBy("status", "ACCEPTED")
+	if err != nil {
+		return nil, err
+	}
+	return c.queryOffersWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// queryOffersWithPagination - helper function for querying one page of offers
+func (c *OfferContract) queryOffersWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int32, bookmark string) (*PaginatedOfferResult, error) {
+	if err := couchquery.ValidatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	offers := []*Offer{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		objectType, _, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || objectType != offerObjectType {
+			continue
+		}
+
+		var offer Offer
+		err = json.Unmarshal(queryResponse.Value, &offer)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, &offer)
+	}
+
+	return &PaginatedOfferResult{
+		Records:      offers,
+		Bookmark:     metadata.Bookmark,
+		FetchedCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+// offersBy builds the query for offers whose field equals value
+func offersBy(field string, value string) (string, error) {
+	return couchquery.Build(map[string]interface{}{field: value})
+}
+
 // queryOffers - helper function for querying offers
 func (c *OfferContract) queryOffers(ctx contractapi.TransactionContextInterface, queryString string) ([]*Offer, error) {
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
@@ -290,11 +481,15 @@ func (c *OfferContract) queryOffers(ctx contractapi.TransactionContextInterface,
 		if err != nil {
 			return nil, err
 		}
+		objectType, _, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || objectType != offerObjectType {
+			continue
+		}
 
 		var offer Offer
 		err = json.Unmarshal(queryResponse.Value, &offer)
 		if err != nil {
-			continue
+			return nil, err
 		}
 		offers = append(offers, &offer)
 	}
@@ -304,7 +499,7 @@ func (c *OfferContract) queryOffers(ctx contractapi.TransactionContextInterface,
 
 // GetAllOffers retrieves all offers
 func (c *OfferContract) GetAllOffers(ctx contractapi.TransactionContextInterface) ([]*Offer, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(offerObjectType, []string{})
 	if err != nil {
 		return nil, err
 	}
@@ -320,7 +515,7 @@ func (c *OfferContract) GetAllOffers(ctx contractapi.TransactionContextInterface
 		var offer Offer
 		err = json.Unmarshal(queryResponse.Value, &offer)
 		if err != nil {
-			continue
+			return nil, err
 		}
 		offers = append(offers, &offer)
 	}
@@ -328,9 +523,88 @@ func (c *OfferContract) GetAllOffers(ctx contractapi.TransactionContextInterface
 	return offers, nil
 }
 
+// GetAllOffersWithPagination retrieves one page of all offers
+func (c *OfferContract) GetAllOffersWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedOfferResult, error) {
+	if err := couchquery.ValidatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(offerObjectType, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	offers := []*Offer{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var offer Offer
+		err = json.Unmarshal(queryResponse.Value, &offer)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, &offer)
+	}
+
+	return &PaginatedOfferResult{
+		Records:      offers,
+		Bookmark:     metadata.Bookmark,
+		FetchedCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+// MigrateKeys rewrites offers stored under plain offer IDs to composite keys and
+// returns how many were moved. Running it again after a migration is a no-op.
+func (c *OfferContract) MigrateKeys(ctx contractapi.TransactionContextInterface) (int, error) {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return 0, err
+	}
+
+	// GetStateByRange only returns plain keys, never composite ones
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return migrated, err
+		}
+
+		key, err := offerKey(ctx, queryResponse.Key)
+		if err != nil {
+			return migrated, err
+		}
+
+		err = ctx.GetStub().PutState(key, queryResponse.Value)
+		if err != nil {
+			return migrated, err
+		}
+		err = ctx.GetStub().DelState(queryResponse.Key)
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
 // GetOfferHistory retrieves the history of an offer
 func (c *OfferContract) GetOfferHistory(ctx contractapi.TransactionContextInterface, offerID string) ([]map[string]interface{}, error) {
-	resultsIterator, err := ctx.GetStub().GetHistoryForKey(offerID)
+	key, err := offerKey(ctx, offerID)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// propertyChaincodeName is the chaincode holding property titles on the same channel
+const propertyChaincodeName = "property-contract"
+
+// weiPerUnit converts offer amounts, held in Wei, to the currency units the
+// property contract values properties in
+const weiPerUnit = 1e18
+
+// saleEligibility mirrors the property contract's SaleEligibility
+type saleEligibility struct {
+	PropertyID string `json:"propertyId"`
+	Eligible   bool   `json:"eligible"`
+	Encumbered bool   `json:"encumbered"`
+	Tenanted   bool   `json:"tenanted"`
+	Frozen     bool   `json:"frozen"`
+	Reason     string `json:"reason"`
+}
+
+// guidelineCheck mirrors the property contract's GuidelineCheck
+type guidelineCheck struct {
+	PropertyID     string  `json:"propertyId"`
+	Amount         float64 `json:"amount"`
+	HasGuideline   bool    `json:"hasGuideline"`
+	GuidelineValue float64 `json:"guidelineValue"`
+	BelowGuideline bool    `json:"belowGuideline"`
+}
+
+// invokePropertyContract calls a property-contract function and decodes its JSON
+// result when result is non-nil
+func invokePropertyContract(ctx contractapi.TransactionContextInterface, result interface{}, function string, args ...string) error {
+	invokeArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+
+	response := ctx.GetStub().InvokeChaincode(propertyChaincodeName, invokeArgs, "")
+	if response.Status >= shim.ERRORTHRESHOLD {
+		return fmt.Errorf("%s %s failed: %s", propertyChaincodeName, function, response.Message)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	err := json.Unmarshal(response.Payload, result)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %v", propertyChaincodeName, function, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestOffersBy(t *testing.T) {
+	tests := []struct {
+		field string
+		value string
+		want  string
+	}{
+		{"propertyId", "PROP_001", `{"selector":{"propertyId":"PROP_001"}}`},
+		{"buyerId", "Org1MSP::alice", `{"selector":{"buyerId":"Org1MSP::alice"}}`},
+		{"sellerId", "Org2MSP::bob", `{"selector":{"sellerId":"Org2MSP::bob"}}`},
+		{"status", "ACCEPTED", `{"selector":{"status":"ACCEPTED"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			queryString, err := offersBy(tt.field, tt.value)
+			if err != nil {
+				t.Fatalf("offersBy: %v", err)
+			}
+			if queryString != tt.want {
+				t.Fatalf("query = %s, want %s", queryString, tt.want)
+			}
+		})
+	}
+}
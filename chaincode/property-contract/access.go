@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// requireOwner returns the caller if it holds a share of the property
+func requireOwner(ctx contractapi.TransactionContextInterface, property *Property) (*access.Caller, error) {
+	caller, err := access.GetCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !property.isOwner(caller.ID) {
+		return nil, fmt.Errorf("access denied: caller %s is not the owner of property %s", caller.ID, property.PropertyID)
+	}
+	return caller, nil
+}
+
+// requireOwnerOrRegistrarAdmin returns the caller if it holds a share of the property or is a registrar admin
+func requireOwnerOrRegistrarAdmin(ctx contractapi.TransactionContextInterface, property *Property) (*access.Caller, error) {
+	caller, err := access.GetCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !property.isOwner(caller.ID) && !caller.IsRegistrarAdmin() {
+		return nil, fmt.Errorf("access denied: caller %s is neither the owner of property %s nor a registrar admin", caller.ID, property.PropertyID)
+	}
+	return caller, nil
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// parcelObjectType indexes parcel boundaries by the geohash cells they touch
+	parcelObjectType = "parcel"
+
+	// parcelCellPrecision is the geohash precision of the boundary index (cells of roughly 5km x 5km)
+	parcelCellPrecision = 5
+
+	// squareMetresPerAreaUnit converts Property.Area, captured in square feet, to square metres
+	squareMetresPerAreaUnit = 0.09290304
+
+	// boundaryAreaTolerance is how far a boundary's area may drift from the declared area
+	boundaryAreaTolerance = 0.25
+
+	// overlapTolerance is the share of the smaller parcel that may overlap a neighbour,
+	// absorbing survey error along shared edges
+	overlapTolerance = 0.02
+
+	// overlapSamples is the grid resolution used to estimate overlapping area
+	overlapSamples = 64
+
+	metresPerDegreeLat = 110540.0
+	metresPerDegreeLon = 111320.0
+)
+
+// GeoPolygon is a GeoJSON Polygon; coordinates are [ring][point][longitude, latitude]
+// and only the outer ring describes a parcel
+type GeoPolygon struct {
+	Type        string        `json:"type"`
+	Coordinates [][][]float64 `json:"coordinates"`
+}
+
+// point is a vertex in degrees
+type point struct {
+	lon float64
+	lat float64
+}
+
+// parseBoundary decodes and validates a GeoJSON polygon, checking it against the declared area
+func parseBoundary(boundaryJSON string, declaredArea float64) (*GeoPolygon, error) {
+	var boundary GeoPolygon
+	err := json.Unmarshal([]byte(boundaryJSON), &boundary)
+	if err != nil {
+		return nil, fmt.Errorf("invalid boundary: %v", err)
+	}
+
+	if err := validateBoundary(&boundary, declaredArea); err != nil {
+		return nil, err
+	}
+	return &boundary, nil
+}
+
+// validateBoundary checks that a polygon is a simple closed ring enclosing roughly the declared area
+func validateBoundary(boundary *GeoPolygon, declaredArea float64) error {
+	if boundary.Type != "Polygon" {
+		return fmt.Errorf("boundary must be a GeoJSON Polygon, got %q", boundary.Type)
+	}
+	if len(boundary.Coordinates) == 0 {
+		return fmt.Errorf("boundary has no rings")
+	}
+
+	ring, err := boundary.ring()
+	if err != nil {
+		return err
+	}
+	if len(ring) < 4 {
+		return fmt.Errorf("boundary ring needs at least 4 positions, got %d", len(ring))
+	}
+	if ring[0] != ring[len(ring)-1] {
+		return fmt.Errorf("boundary ring is not closed")
+	}
+	if selfIntersects(ring) {
+		return fmt.Errorf("boundary ring intersects itself")
+	}
+
+	area := ringAreaSquareMetres(ring)
+	if area == 0 {
+		return fmt.Errorf("boundary ring encloses no area")
+	}
+	declared := declaredArea * squareMetresPerAreaUnit
+	if math.Abs(area-declared) > boundaryAreaTolerance*declared {
+		return fmt.Errorf("boundary encloses %.0f m² but the declared area is %.0f m²", area, declared)
+	}
+
+	return nil
+}
+
+// ring returns the validated outer ring of the polygon
+func (g *GeoPolygon) ring() ([]point, error) {
+	var ring []point
+	for _, position := range g.Coordinates[0] {
+		if len(position) != 2 {
+			return nil, fmt.Errorf("boundary positions must be [longitude, latitude]")
+		}
+		if err := validateCoordinates(position[1], position[0]); err != nil {
+			return nil, err
+		}
+		ring = append(ring, point{lon: position[0], lat: position[1]})
+	}
+	return ring, nil
+}
+
+// bounds returns the bounding box of a ring
+func bounds(ring []point) (float64, float64, float64, float64) {
+	minLat, minLon := math.Inf(1), math.Inf(1)
+	maxLat, maxLon := math.Inf(-1), math.Inf(-1)
+	for _, p := range ring {
+		minLat, maxLat = math.Min(minLat, p.lat), math.Max(maxLat, p.lat)
+		minLon, maxLon = math.Min(minLon, p.lon), math.Max(maxLon, p.lon)
+	}
+	return minLat, minLon, maxLat, maxLon
+}
+
+// ringAreaSquareMetres computes the area of a closed ring on a local equirectangular projection
+func ringAreaSquareMetres(ring []point) float64 {
+	minLat, _, maxLat, _ := bounds(ring)
+	lonScale := metresPerDegreeLon * math.Cos((minLat+maxLat)/2*math.Pi/180)
+
+	sum := 0.0
+	for i := 0; i < len(ring)-1; i++ {
+		sum += ring[i].lon*lonScale*ring[i+1].lat*metresPerDegreeLat - ring[i+1].lon*lonScale*ring[i].lat*metresPerDegreeLat
+	}
+	return math.Abs(sum) / 2
+}
+
+// selfIntersects reports whether any two non-adjacent edges of a closed ring touch
+func selfIntersects(ring []point) bool {
+	edges := len(ring) - 1
+	for i := 0; i < edges; i++ {
+		for j := i + 1; j < edges; j++ {
+			if j == i+1 || (i == 0 && j == edges-1) {
+				continue
+			}
+			if segmentsIntersect(ring[i], ring[i+1], ring[j], ring[j+1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// segmentsIntersect reports whether segments p1-p2 and q1-q2 share any point
+func segmentsIntersect(p1 point, p2 point, q1 point, q2 point) bool {
+	d1 := orientation(q1, q2, p1)
+	d2 := orientation(q1, q2, p2)
+	d3 := orientation(p1, p2, q1)
+	d4 := orientation(p1, p2, q2)
+
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+
+	return (d1 == 0 && onSegment(q1, q2, p1)) ||
+		(d2 == 0 && onSegment(q1, q2, p2)) ||
+		(d3 == 0 && onSegment(p1, p2, q1)) ||
+		(d4 == 0 && onSegment(p1, p2, q2))
+}
+
+// orientation returns the cross product of (b - a) and (c - a)
+func orientation(a point, b point, c point) float64 {
+	return (b.lon-a.lon)*(c.lat-a.lat) - (b.lat-a.lat)*(c.lon-a.lon)
+}
+
+// onSegment reports whether a collinear point c lies within segment a-b
+func onSegment(a point, b point, c point) bool {
+	return c.lon >= math.Min(a.lon, b.lon) && c.lon <= math.Max(a.lon, b.lon) &&
+		c.lat >= math.Min(a.lat, b.lat) && c.lat <= math.Max(a.lat, b.lat)
+}
+
+// containsPoint reports whether a point lies inside a closed ring (ray casting)
+func containsPoint(ring []point, p point) bool {
+	inside := false
+	for i, j := 0, len(ring)-2; i < len(ring)-1; j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.lat > p.lat) != (b.lat > p.lat) &&
+			p.lon < (b.lon-a.lon)*(p.lat-a.lat)/(b.lat-a.lat)+a.lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// overlapSquareMetres estimates the area shared by two rings by sampling a grid
+// over the intersection of their bounding boxes
+func overlapSquareMetres(a []point, b []point) float64 {
+	aMinLat, aMinLon, aMaxLat, aMaxLon := bounds(a)
+	bMinLat, bMinLon, bMaxLat, bMaxLon := bounds(b)
+	minLat, maxLat := math.Max(aMinLat, bMinLat), math.Min(aMaxLat, bMaxLat)
+	minLon, maxLon := math.Max(aMinLon, bMinLon), math.Min(aMaxLon, bMaxLon)
+	if minLat >= maxLat || minLon >= maxLon {
+		return 0
+	}
+
+	latStep := (maxLat - minLat) / overlapSamples
+	lonStep := (maxLon - minLon) / overlapSamples
+	shared := 0
+	for row := 0; row < overlapSamples; row++ {
+		for col := 0; col < overlapSamples; col++ {
+			sample := point{lon: minLon + (float64(col)+0.5)*lonStep, lat: minLat + (float64(row)+0.5)*latStep}
+			if containsPoint(a, sample) && containsPoint(b, sample) {
+				shared++
+			}
+		}
+	}
+
+	boxArea := (maxLat - minLat) * metresPerDegreeLat * (maxLon - minLon) * metresPerDegreeLon * math.Cos((minLat+maxLat)/2*math.Pi/180)
+	return boxArea * float64(shared) / (overlapSamples * overlapSamples)
+}
+
+// boundaryCells returns the parcel index cells touched by a boundary
+func boundaryCells(boundary *GeoPolygon) ([]string, error) {
+	ring, err := boundary.ring()
+	if err != nil {
+		return nil, err
+	}
+	minLat, minLon, maxLat, maxLon := bounds(ring)
+	return cellsAtPrecision(minLat, minLon, maxLat, maxLon, parcelCellPrecision), nil
+}
+
+// putParcelIndex writes the parcel index entries of a property's boundary
+func putParcelIndex(ctx contractapi.TransactionContextInterface, property *Property) error {
+	cells, err := boundaryCells(property.Boundary)
+	if err != nil {
+		return err
+	}
+
+	for _, cell := range cells {
+		key, err := ctx.GetStub().CreateCompositeKey(parcelObjectType, []string{cell, property.PropertyID})
+		if err != nil {
+			return err
+		}
+		// Index entries carry no data; a single byte is stored because an empty value deletes the key
+		err = ctx.GetStub().PutState(key, []byte{0x00})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkParcelOverlap rejects a boundary that overlaps a registered parcel beyond
+// overlapTolerance. Parcels listed in ignore (e.g. the parent of a subdivision)
+// are not compared.
+func (c *PropertyContract) checkParcelOverlap(ctx contractapi.TransactionContextInterface, propertyID string, boundary *GeoPolygon, ignore ...string) error {
+	ring, err := boundary.ring()
+	if err != nil {
+		return err
+	}
+	area := ringAreaSquareMetres(ring)
+
+	cells, err := boundaryCells(boundary)
+	if err != nil {
+		return err
+	}
+
+	checked := map[string]bool{propertyID: true}
+	for _, id := range ignore {
+		checked[id] = true
+	}
+
+	for _, cell := range cells {
+		resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(parcelObjectType, []string{cell})
+		if err != nil {
+			return err
+		}
+
+		var candidates []string
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return err
+			}
+			_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+			if err != nil {
+				resultsIterator.Close()
+				return err
+			}
+			if !checked[attributes[1]] {
+				checked[attributes[1]] = true
+				candidates = append(candidates, attributes[1])
+			}
+		}
+		resultsIterator.Close()
+
+		for _, candidateID := range candidates {
+			existing, err := c.getProperty(ctx, candidateID)
+			if err != nil {
+				return err
+			}
+			if existing.Boundary == nil || !isRegisteredParcel(existing.Status) {
+				continue
+			}
+
+			existingRing, err := existing.Boundary.ring()
+			if err != nil {
+				return err
+			}
+
+			overlap := overlapSquareMetres(ring, existingRing)
+			if overlap > overlapTolerance*math.Min(area, ringAreaSquareMetres(existingRing)) {
+				return newContractError(errCodeParcelOverlap, "boundary of property %s overlaps registered parcel %s by %.0f m²", propertyID, existing.PropertyID, overlap)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isRegisteredParcel reports whether a parcel in this status still holds its land
+func isRegisteredParcel(status string) bool {
+	return status != statusRejected && status != statusRetired
+}
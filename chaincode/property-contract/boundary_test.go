@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// squareBoundary returns a closed GeoJSON ring for a square parcel of side
+// degrees with its south-west corner at lat, lon
+func squareBoundary(lat float64, lon float64, side float64) *GeoPolygon {
+	return &GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{{
+		{lon, lat}, {lon + side, lat}, {lon + side, lat + side}, {lon, lat + side}, {lon, lat},
+	}}}
+}
+
+// squareFeet converts the area a boundary encloses to the unit Property.Area uses
+func squareFeet(t *testing.T, boundary *GeoPolygon) float64 {
+	t.Helper()
+
+	ring, err := boundary.ring()
+	if err != nil {
+		t.Fatalf("ring: %v", err)
+	}
+	return ringAreaSquareMetres(ring) / squareMetresPerAreaUnit
+}
+
+func TestValidateBoundary(t *testing.T) {
+	square := squareBoundary(12.97, 77.59, 0.001)
+	declared := squareFeet(t, square)
+
+	tests := []struct {
+		name     string
+		boundary *GeoPolygon
+		declared float64
+		wantErr  bool
+	}{
+		{"square matching the declared area", square, declared, false},
+		{"area within tolerance", square, declared * 1.2, false},
+		{"area beyond tolerance", square, declared * 2, true},
+		{"not a polygon", &GeoPolygon{Type: "Point", Coordinates: square.Coordinates}, declared, true},
+		{"no rings", &GeoPolygon{Type: "Polygon"}, declared, true},
+		{"too few positions", &GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{{
+			{77.59, 12.97}, {77.591, 12.97}, {77.59, 12.97},
+		}}}, declared, true},
+		{"ring not closed", &GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{{
+			{77.59, 12.97}, {77.591, 12.97}, {77.591, 12.971}, {77.59, 12.971},
+		}}}, declared, true},
+		{"self-intersecting bow tie", &GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{{
+			{77.59, 12.97}, {77.591, 12.971}, {77.591, 12.97}, {77.59, 12.971}, {77.59, 12.97},
+		}}}, declared, true},
+		{"collinear ring", &GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{{
+			{77.59, 12.97}, {77.5905, 12.97}, {77.591, 12.97}, {77.59, 12.97},
+		}}}, declared, true},
+		{"position without latitude", &GeoPolygon{Type: "Polygon", Coordinates: [][][]float64{{
+			{77.59}, {77.591, 12.97}, {77.591, 12.971}, {77.59},
+		}}}, declared, true},
+		{"latitude off the globe", squareBoundary(90, 77.59, 0.001), declared, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBoundary(tt.boundary, tt.declared)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateBoundary = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRingAreaSquareMetres(t *testing.T) {
+	ring, err := squareBoundary(0, 0, 0.001).ring()
+	if err != nil {
+		t.Fatalf("ring: %v", err)
+	}
+
+	// At the equator a 0.001° square is about 110.54 m by 111.32 m
+	want := 0.001 * metresPerDegreeLat * 0.001 * metresPerDegreeLon
+	if got := ringAreaSquareMetres(ring); math.Abs(got-want) > want*0.001 {
+		t.Fatalf("ringAreaSquareMetres = %.1f, want %.1f", got, want)
+	}
+
+	// Winding order does not change the area
+	reversed := make([]point, len(ring))
+	for i, p := range ring {
+		reversed[len(ring)-1-i] = p
+	}
+	if got := ringAreaSquareMetres(reversed); math.Abs(got-want) > want*0.001 {
+		t.Fatalf("reversed ringAreaSquareMetres = %.1f, want %.1f", got, want)
+	}
+}
+
+func TestOverlapSquareMetres(t *testing.T) {
+	const side = 0.001
+	base := squareBoundary(12.97, 77.59, side)
+
+	tests := []struct {
+		name      string
+		other     *GeoPolygon
+		wantShare float64 // of the base parcel's area
+	}{
+		{"same parcel", squareBoundary(12.97, 77.59, side), 1},
+		{"half overlapping", squareBoundary(12.97, 77.59+side/2, side), 0.5},
+		{"quarter overlapping", squareBoundary(12.97+side/2, 77.59+side/2, side), 0.25},
+		{"sharing an edge", squareBoundary(12.97, 77.59+side, side), 0},
+		{"far apart", squareBoundary(13.5, 78.0, side), 0},
+	}
+
+	baseRing, err := base.ring()
+	if err != nil {
+		t.Fatalf("ring: %v", err)
+	}
+	baseArea := ringAreaSquareMetres(baseRing)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otherRing, err := tt.other.ring()
+			if err != nil {
+				t.Fatalf("ring: %v", err)
+			}
+
+			share := overlapSquareMetres(baseRing, otherRing) / baseArea
+			if math.Abs(share-tt.wantShare) > 0.05 {
+				t.Fatalf("overlap share = %.3f, want %.2f", share, tt.wantShare)
+			}
+			if tt.wantShare == 0 && share > overlapTolerance {
+				t.Fatalf("overlap share %.3f exceeds the tolerance for neighbours", share)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"access"
+	"couchquery"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// disputeObjectType keys disputes by property so they can be listed per parcel
+const disputeObjectType = "dispute"
+
+// Dispute statuses
+const (
+	disputeOpen     = "OPEN"
+	disputeResolved = "RESOLVED"
+)
+
+// Dispute outcomes
+const (
+	outcomeUpheld    = "UPHELD"
+	outcomeDismissed = "DISMISSED"
+	outcomeSettled   = "SETTLED"
+)
+
+// Dispute steps
+const (
+	disputeStepRaised   = "RAISED"
+	disputeStepFrozen   = "FROZEN"
+	disputeStepResolved = "RESOLVED"
+)
+
+// Dispute is a contested claim to a property's title. While a dispute has
+// frozen the property its title, price and status cannot change.
+type Dispute struct {
+	DisputeID      string        `json:"disputeId"`
+	PropertyID     string        `json:"propertyId"`
+	ClaimantID     string        `json:"claimantId"`
+	RespondentIDs  []string      `json:"respondentIds"` // the owners when the dispute was raised
+	EvidenceHash   string        `json:"evidenceHash"`  // lowercase hex SHA-256 of the claimant's evidence
+	Description    string        `json:"description"`
+	Status         string        `json:"status"` // OPEN, RESOLVED
+	Frozen         bool          `json:"frozen"`
+	CourtReference string        `json:"courtReference"`
+	Outcome        string        `json:"outcome"` // UPHELD, DISMISSED, SETTLED
+	Steps          []DisputeStep `json:"steps"`
+}
+
+// DisputeStep is one recorded step of a dispute
+type DisputeStep struct {
+	Action    string    `json:"action"` // RAISED, FROZEN, RESOLVED
+	ActorID   string    `json:"actorId"`
+	Comment   string    `json:"comment"`
+	TxID      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// disputeKey returns the composite ledger key of a dispute
+func disputeKey(ctx contractapi.TransactionContextInterface, propertyID string, disputeID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(disputeObjectType, []string{propertyID, disputeID})
+}
+
+// isFrozen reports whether a dispute has frozen the property
+func (p *Property) isFrozen() bool {
+	return len(p.FrozenBy) > 0
+}
+
+// requireNotFrozen rejects changes to a property frozen by a dispute
+func requireNotFrozen(property *Property) error {
+	if property.isFrozen() {
+		return newContractError(errCodePropertyFrozen, "property %s is frozen by disputes %v", property.PropertyID, property.FrozenBy)
+	}
+	return nil
+}
+
+// RaiseDispute records a claim against a property's title with the SHA-256 of
+// the claimant's evidence. The claimant raises it, or a registrar on their behalf.
+func (c *PropertyContract) RaiseDispute(ctx contractapi.TransactionContextInterface, propertyID string, disputeID string, claimant string, evidenceHash string, description string) error {
+	caller, err := access.GetCaller(ctx)
+	if err != nil {
+		return err
+	}
+	if caller.ID != claimant && !caller.IsRegistrar() {
+		return fmt.Errorf("access denied: caller %s is neither the claimant %s nor a registrar", caller.ID, claimant)
+	}
+
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	if property.Status == statusRetired {
+		return fmt.Errorf("property %s is %s", propertyID, property.Status)
+	}
+	if disputeID == "" {
+		return fmt.Errorf("dispute ID is required")
+	}
+	if err := access.ValidateIdentityID(claimant); err != nil {
+		return err
+	}
+	evidenceHash, err = normalizeDocumentHash(evidenceHash)
+	if err != nil {
+		return err
+	}
+
+	key, err := disputeKey(ctx, propertyID, disputeID)
+	if err != nil {
+		return err
+	}
+	existingJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read dispute: %v", err)
+	}
+	if existingJSON != nil {
+		return fmt.Errorf("dispute %s already exists on property %s", disputeID, propertyID)
+	}
+
+	var respondents []string
+	for _, owner := range property.ownerShares() {
+		respondents = append(respondents, owner.OwnerID)
+	}
+
+	dispute := &Dispute{
+		DisputeID:     disputeID,
+		PropertyID:    propertyID,
+		ClaimantID:    claimant,
+		RespondentIDs: respondents,
+		EvidenceHash:  evidenceHash,
+		Description:   description,
+		Status:        disputeOpen,
+		Steps:         []DisputeStep{},
+	}
+	if err := addDisputeStep(ctx, dispute, caller, disputeStepRaised, description); err != nil {
+		return err
+	}
+
+	return putDispute(ctx, dispute)
+}
+
+// FreezeProperty freezes a property for an open dispute under a court order or
+// registrar decision. Until the dispute is resolved the property rejects price
+// updates, offers, transfers and status changes. Registrar admin only.
+func (c *PropertyContract) FreezeProperty(ctx contractapi.TransactionContextInterface, propertyID string, disputeID string, courtReference string) error {
+	caller, err := access.RequireRegistrarAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if courtReference == "" {
+		return fmt.Errorf("the reference of the order or decision to freeze is required")
+	}
+
+	dispute, err := getDispute(ctx, propertyID, disputeID)
+	if err != nil {
+		return err
+	}
+	if dispute.Status != disputeOpen {
+		return fmt.Errorf("dispute %s is %s", disputeID, dispute.Status)
+	}
+	if dispute.Frozen {
+		return fmt.Errorf("dispute %s has already frozen property %s", disputeID, propertyID)
+	}
+
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	dispute.Frozen = true
+	dispute.CourtReference = courtReference
+	if err := addDisputeStep(ctx, dispute, caller, disputeStepFrozen, courtReference); err != nil {
+		return err
+	}
+	if err := putDispute(ctx, dispute); err != nil {
+		return err
+	}
+
+	property.FrozenBy = append(property.FrozenBy, disputeID)
+	property.LastUpdated = dispute.Steps[len(dispute.Steps)-1].Timestamp
+
+	return c.putProperty(ctx, property)
+}
+
+// ResolveDispute closes a dispute as UPHELD, DISMISSED or SETTLED and lifts its
+// freeze. A title change ordered by the outcome is then recorded with
+// TransferProperty as a COURT_ORDER. Registrar admin only.
+func (c *PropertyContract) ResolveDispute(ctx contractapi.TransactionContextInterface, propertyID string, disputeID string, outcome string, comment string) error {
+	caller, err := access.RequireRegistrarAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if outcome != outcomeUpheld && outcome != outcomeDismissed && outcome != outcomeSettled {
+		return fmt.Errorf("unknown dispute outcome %q", outcome)
+	}
+
+	dispute, err := getDispute(ctx, propertyID, disputeID)
+	if err != nil {
+		return err
+	}
+	if dispute.Status != disputeOpen {
+		return fmt.Errorf("dispute %s is already %s", disputeID, dispute.Status)
+	}
+
+	dispute.Status = disputeResolved
+	dispute.Outcome = outcome
+	if err := addDisputeStep(ctx, dispute, caller, disputeStepResolved, comment); err != nil {
+		return err
+	}
+	if err := putDispute(ctx, dispute); err != nil {
+		return err
+	}
+
+	if !dispute.Frozen {
+		return nil
+	}
+
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	var frozenBy []string
+	for _, id := range property.FrozenBy {
+		if id != disputeID {
+			frozenBy = append(frozenBy, id)
+		}
+	}
+	property.FrozenBy = frozenBy
+	property.LastUpdated = dispute.Steps[len(dispute.Steps)-1].Timestamp
+
+	return c.putProperty(ctx, property)
+}
+
+// GetDispute returns one dispute of a property
+func (c *PropertyContract) GetDispute(ctx contractapi.TransactionContextInterface, propertyID string, disputeID string) (*Dispute, error) {
+	return getDispute(ctx, propertyID, disputeID)
+}
+
+// GetDisputesByProperty returns every dispute, open or resolved, raised against a property
+func (c *PropertyContract) GetDisputesByProperty(ctx contractapi.TransactionContextInterface, propertyID string) ([]*Dispute, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(disputeObjectType, []string{propertyID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	disputes := []*Dispute{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var dispute Dispute
+		err = json.Unmarshal(queryResponse.Value, &dispute)
+		if err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, &dispute)
+	}
+
+	return disputes, nil
+}
+
+// GetDisputesByParty returns the disputes a user raised or was named in as an owner
+func (c *PropertyContract) GetDisputesByParty(ctx contractapi.TransactionContextInterface, partyID string) ([]*Dispute, error) {
+	queryString, err := couchquery.Build(map[string]interface{}{
+		"disputeId": map[string]interface{}{"$exists": true},
+		"$or": []interface{}{
+			map[string]interface{}{"claimantId": partyID},
+			map[string]interface{}{"respondentIds": map[string]interface{}{"$elemMatch": map[string]interface{}{"$eq": partyID}}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	disputes := []*Dispute{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !hasObjectType(ctx, queryResponse.Key, disputeObjectType) {
+			continue
+		}
+
+		var dispute Dispute
+		err = json.Unmarshal(queryResponse.Value, &dispute)
+		if err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, &dispute)
+	}
+
+	return disputes, nil
+}
+
+// addDisputeStep appends a step taken by the caller in the current transaction
+func addDisputeStep(ctx contractapi.TransactionContextInterface, dispute *Dispute, caller *access.Caller, action string, comment string) error {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	dispute.Steps = append(dispute.Steps, DisputeStep{
+		Action:    action,
+		ActorID:   caller.ID,
+		Comment:   comment,
+		TxID:      ctx.GetStub().GetTxID(),
+		Timestamp: time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)),
+	})
+	return nil
+}
+
+// getDispute reads one dispute of a property
+func getDispute(ctx contractapi.TransactionContextInterface, propertyID string, disputeID string) (*Dispute, error) {
+	key, err := disputeKey(ctx, propertyID, disputeID)
+	if err != nil {
+		return nil, err
+	}
+
+	disputeJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dispute: %v", err)
+	}
+	if disputeJSON == nil {
+		return nil, fmt.Errorf("dispute %s does not exist on property %s", disputeID, propertyID)
+	}
+
+	var dispute Dispute
+	err = json.Unmarshal(disputeJSON, &dispute)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dispute, nil
+}
+
+// putDispute writes a dispute under its composite key
+func putDispute(ctx contractapi.TransactionContextInterface, dispute *Dispute) error {
+	key, err := disputeKey(ctx, dispute.PropertyID, dispute.DisputeID)
+	if err != nil {
+		return err
+	}
+
+	disputeJSON, err := json.Marshal(dispute)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, disputeJSON)
+}
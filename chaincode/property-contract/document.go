@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// documentObjectType keys property documents by property
+const documentObjectType = "document"
+
+// Property document statuses
+const (
+	documentActive  = "ACTIVE"
+	documentRevoked = "REVOKED"
+)
+
+// PropertyDocument is a deed, survey plan or other record of a property. Only
+// the SHA-256 of the file is kept on the ledger; the file itself lives at URI.
+type PropertyDocument struct {
+	DocumentID       string    `json:"documentId"`
+	PropertyID       string    `json:"propertyId"`
+	DocumentType     string    `json:"documentType"` // SALE_DEED, SURVEY_PLAN, TAX_RECEIPT, etc.
+	DocumentHash     string    `json:"documentHash"` // lowercase hex SHA-256
+	URI              string    `json:"uri"`
+	UploadedBy       string    `json:"uploadedBy"`
+	UploadedAt       time.Time `json:"uploadedAt"`
+	IsVerified       bool      `json:"isVerified"`
+	VerifiedBy       string    `json:"verifiedBy"`
+	VerifiedAt       time.Time `json:"verifiedAt"`
+	Status           string    `json:"status"` // ACTIVE, REVOKED
+	RevokedBy        string    `json:"revokedBy"`
+	RevokedAt        time.Time `json:"revokedAt"`
+	RevocationReason string    `json:"revocationReason"`
+}
+
+// DocumentHashMatch is the answer to VerifyDocumentHash. Matched is only set for
+// a document that has not been revoked.
+type DocumentHashMatch struct {
+	PropertyID   string            `json:"propertyId"`
+	DocumentHash string            `json:"documentHash"`
+	Matched      bool              `json:"matched"`
+	Document     *PropertyDocument `json:"document,omitempty" metadata:",optional"`
+}
+
+// documentKey returns the composite ledger key of a property document
+func documentKey(ctx contractapi.TransactionContextInterface, propertyID string, documentID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(documentObjectType, []string{propertyID, documentID})
+}
+
+// normalizeDocumentHash lowercases a hex SHA-256 and checks its length
+func normalizeDocumentHash(documentHash string) (string, error) {
+	documentHash = strings.ToLower(strings.TrimSpace(documentHash))
+	decoded, err := hex.DecodeString(documentHash)
+	if err != nil || len(decoded) != 32 {
+		return "", fmt.Errorf("document hash must be a hex-encoded SHA-256")
+	}
+	return documentHash, nil
+}
+
+// AddPropertyDocument registers the hash of a document held off-chain; an owner
+// or a registrar may add documents
+func (c *PropertyContract) AddPropertyDocument(ctx contractapi.TransactionContextInterface, propertyID string, documentID string, documentType string, documentHash string, uri string) error {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	caller, err := access.GetCaller(ctx)
+	if err != nil {
+		return err
+	}
+	if !property.isOwner(caller.ID) && !caller.IsRegistrar() {
+		return fmt.Errorf("access denied: caller %s is neither an owner of property %s nor a registrar", caller.ID, propertyID)
+	}
+	if property.Status == statusRetired {
+		return fmt.Errorf("property %s is %s", propertyID, property.Status)
+	}
+
+	if documentID == "" || documentType == "" {
+		return fmt.Errorf("document ID and type are required")
+	}
+	documentHash, err = normalizeDocumentHash(documentHash)
+	if err != nil {
+		return err
+	}
+
+	documents, err := c.GetPropertyDocuments(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	for _, document := range documents {
+		if document.DocumentID == documentID {
+			return fmt.Errorf("document %s already exists on property %s", documentID, propertyID)
+		}
+		if document.DocumentHash == documentHash && document.Status == documentActive {
+			return fmt.Errorf("document %s of property %s already has hash %s", document.DocumentID, propertyID, documentHash)
+		}
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	document := &PropertyDocument{
+		DocumentID:   documentID,
+		PropertyID:   propertyID,
+		DocumentType: documentType,
+		DocumentHash: documentHash,
+		URI:          uri,
+		UploadedBy:   caller.ID,
+		UploadedAt:   timestamp,
+		Status:       documentActive,
+	}
+	if err := putPropertyDocument(ctx, document); err != nil {
+		return err
+	}
+
+	property.Documents = append(property.Documents, documentID)
+	property.LastUpdated = timestamp
+
+	return c.putProperty(ctx, property)
+}
+
+// VerifyPropertyDocument marks a document as checked against the original by a
+// registrar other than the property's owners
+func (c *PropertyContract) VerifyPropertyDocument(ctx contractapi.TransactionContextInterface, propertyID string, documentID string) error {
+	caller, err := access.RequireRegistrar(ctx)
+	if err != nil {
+		return err
+	}
+
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	if property.isOwner(caller.ID) {
+		return fmt.Errorf("access denied: caller %s cannot verify documents of its own property %s", caller.ID, propertyID)
+	}
+
+	document, err := getPropertyDocument(ctx, propertyID, documentID)
+	if err != nil {
+		return err
+	}
+	if document.Status != documentActive {
+		return fmt.Errorf("document %s is %s", documentID, document.Status)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	document.IsVerified = true
+	document.VerifiedBy = caller.ID
+	document.VerifiedAt = timestamp
+
+	return putPropertyDocument(ctx, document)
+}
+
+// RevokePropertyDocument withdraws a document, e.g. a superseded or forged deed.
+// The uploader or a registrar admin may revoke; the record is kept.
+func (c *PropertyContract) RevokePropertyDocument(ctx contractapi.TransactionContextInterface, propertyID string, documentID string, reason string) error {
+	caller, err := access.GetCaller(ctx)
+	if err != nil {
+		return err
+	}
+
+	document, err := getPropertyDocument(ctx, propertyID, documentID)
+	if err != nil {
+		return err
+	}
+	if caller.ID != document.UploadedBy && !caller.IsRegistrarAdmin() {
+		return fmt.Errorf("access denied: caller %s is neither the uploader of document %s nor a registrar admin", caller.ID, documentID)
+	}
+	if document.Status != documentActive {
+		return fmt.Errorf("document %s is already %s", documentID, document.Status)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	document.Status = documentRevoked
+	document.RevokedBy = caller.ID
+	document.RevokedAt = timestamp
+	document.RevocationReason = reason
+
+	return putPropertyDocument(ctx, document)
+}
+
+// GetPropertyDocuments returns every document, active or revoked, of a property
+func (c *PropertyContract) GetPropertyDocuments(ctx contractapi.TransactionContextInterface, propertyID string) ([]*PropertyDocument, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(documentObjectType, []string{propertyID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	documents := []*PropertyDocument{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var document PropertyDocument
+		err = json.Unmarshal(queryResponse.Value, &document)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, &document)
+	}
+
+	return documents, nil
+}
+
+// VerifyDocumentHash checks whether a file's SHA-256 matches a document
+// registered against the property, so a buyer can prove a copy is genuine
+func (c *PropertyContract) VerifyDocumentHash(ctx contractapi.TransactionContextInterface, propertyID string, documentHash string) (*DocumentHashMatch, error) {
+	documentHash, err := normalizeDocumentHash(documentHash)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err := c.GetPropertyDocuments(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	match := &DocumentHashMatch{
+		PropertyID:   propertyID,
+		DocumentHash: documentHash,
+	}
+	for _, document := range documents {
+		if document.DocumentHash != documentHash {
+			continue
+		}
+		// Prefer an active document over a revoked one with the same content
+		match.Document = document
+		if document.Status == documentActive {
+			match.Matched = true
+			break
+		}
+	}
+
+	return match, nil
+}
+
+// getPropertyDocument reads one document of a property
+func getPropertyDocument(ctx contractapi.TransactionContextInterface, propertyID string, documentID string) (*PropertyDocument, error) {
+	key, err := documentKey(ctx, propertyID, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	documentJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %v", err)
+	}
+	if documentJSON == nil {
+		return nil, fmt.Errorf("document %s does not exist on property %s", documentID, propertyID)
+	}
+
+	var document PropertyDocument
+	err = json.Unmarshal(documentJSON, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	return &document, nil
+}
+
+// putPropertyDocument writes a property document under its composite key
+func putPropertyDocument(ctx contractapi.TransactionContextInterface, document *PropertyDocument) error {
+	key, err := documentKey(ctx, document.PropertyID, document.DocumentID)
+	if err != nil {
+		return err
+	}
+
+	documentJSON, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, documentJSON)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// SaleEligibility tells the offer contract whether a property may receive new
+// offers. A tenanted property can still be sold, subject to its leases.
+type SaleEligibility struct {
+	PropertyID string `json:"propertyId"`
+	Eligible   bool   `json:"eligible"`
+	Encumbered bool   `json:"encumbered"`
+	Tenanted   bool   `json:"tenanted"`
+	Frozen     bool   `json:"frozen"`
+	Reason     string `json:"reason"`
+}
+
+// GetSaleEligibility reports whether a property can be offered for sale
+func (c *PropertyContract) GetSaleEligibility(ctx contractapi.TransactionContextInterface, propertyID string) (*SaleEligibility, error) {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	eligibility := &SaleEligibility{
+		PropertyID: propertyID,
+		Eligible:   true,
+	}
+
+	if err := c.requireUnencumbered(ctx, propertyID); err != nil {
+		if _, ok := err.(*ContractError); !ok {
+			return nil, err
+		}
+		eligibility.Eligible = false
+		eligibility.Encumbered = true
+		eligibility.Reason = err.Error()
+	}
+
+	// A freeze outranks an encumbrance as the reason given
+	if err := requireNotFrozen(property); err != nil {
+		eligibility.Eligible = false
+		eligibility.Frozen = true
+		eligibility.Reason = err.Error()
+	}
+
+	leases, err := c.GetActiveLeases(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	eligibility.Tenanted = len(leases) > 0
+
+	return eligibility, nil
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// encumbranceObjectType keys encumbrances by property so they can be listed per parcel
+const encumbranceObjectType = "encumbrance"
+
+// Encumbrance types
+const (
+	encumbranceMortgage = "MORTGAGE"
+	encumbranceLien     = "LIEN"
+)
+
+// Encumbrance statuses
+const (
+	encumbranceActive     = "ACTIVE"
+	encumbranceDischarged = "DISCHARGED"
+)
+
+// Encumbrance is a mortgage or lien registered against a property by the
+// organization holding it. While it is ACTIVE the title cannot change hands.
+type Encumbrance struct {
+	EncumbranceID string    `json:"encumbranceId"`
+	PropertyID    string    `json:"propertyId"`
+	Type          string    `json:"type"` // MORTGAGE, LIEN
+	HolderMSPID   string    `json:"holderMspId"`
+	RegisteredBy  string    `json:"registeredBy"`
+	Amount        float64   `json:"amount"`
+	StartDate     time.Time `json:"startDate"`
+	EndDate       time.Time `json:"endDate"` // zero when open-ended
+	Status        string    `json:"status"`  // ACTIVE, DISCHARGED
+	CreatedAt     time.Time `json:"createdAt"`
+	DischargedBy  string    `json:"dischargedBy"`
+	DischargedAt  time.Time `json:"dischargedAt"`
+}
+
+// encumbranceKey returns the composite ledger key of an encumbrance
+func encumbranceKey(ctx contractapi.TransactionContextInterface, propertyID string, encumbranceID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(encumbranceObjectType, []string{propertyID, encumbranceID})
+}
+
+// encumbranceHoldersPolicyName is the key attribute of the list of
+// organizations allowed to register encumbrances
+const encumbranceHoldersPolicyName = "encumbrance-holders"
+
+// EncumbranceHolders lists the lender and court organizations whose admins may
+// register encumbrances. It is maintained by the registrar.
+type EncumbranceHolders struct {
+	HolderMSPIDs []string `json:"holderMspIds"`
+}
+
+// allowsMSP reports whether an organization may register encumbrances
+func (h *EncumbranceHolders) allowsMSP(mspID string) bool {
+	for _, allowed := range h.HolderMSPIDs {
+		if allowed == mspID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetEncumbranceHolders returns the organizations allowed to register
+// encumbrances; none are until SetEncumbranceHolders is called
+func (c *PropertyContract) GetEncumbranceHolders(ctx contractapi.TransactionContextInterface) (*EncumbranceHolders, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(policyObjectType, []string{encumbranceHoldersPolicyName})
+	if err != nil {
+		return nil, err
+	}
+
+	holdersJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encumbrance holders: %v", err)
+	}
+	if holdersJSON == nil {
+		return &EncumbranceHolders{HolderMSPIDs: []string{}}, nil
+	}
+
+	var holders EncumbranceHolders
+	err = json.Unmarshal(holdersJSON, &holders)
+	if err != nil {
+		return nil, err
+	}
+
+	return &holders, nil
+}
+
+// SetEncumbranceHolders replaces the organizations allowed to register
+// encumbrances. Delisting an organization does not stop it discharging the
+// encumbrances it already holds. Registrar admin only.
+func (c *PropertyContract) SetEncumbranceHolders(ctx contractapi.TransactionContextInterface, holderMSPIDsJSON string) error {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return err
+	}
+
+	var holderMSPIDs []string
+	err := json.Unmarshal([]byte(holderMSPIDsJSON), &holderMSPIDs)
+	if err != nil {
+		return fmt.Errorf("invalid holder MSP IDs: %v", err)
+	}
+	for _, mspID := range holderMSPIDs {
+		if mspID == "" {
+			return fmt.Errorf("holder MSP IDs cannot be empty")
+		}
+	}
+	if holderMSPIDs == nil {
+		holderMSPIDs = []string{}
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(policyObjectType, []string{encumbranceHoldersPolicyName})
+	if err != nil {
+		return err
+	}
+
+	holdersJSON, err := json.Marshal(&EncumbranceHolders{HolderMSPIDs: holderMSPIDs})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, holdersJSON)
+}
+
+// requireOrgAdmin returns the caller if it is an ADMIN of its organization,
+// the identity a bank or court uses to manage its encumbrances
+func requireOrgAdmin(ctx contractapi.TransactionContextInterface) (*access.Caller, error) {
+	caller, err := access.GetCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.HasRole(access.RoleAdmin) {
+		return nil, fmt.Errorf("access denied: caller %s must be an ADMIN of its organization to manage encumbrances", caller.ID)
+	}
+	return caller, nil
+}
+
+// requireHolderAdmin returns the caller if it is an ADMIN of an organization
+// the registrar has listed as an encumbrance holder
+func (c *PropertyContract) requireHolderAdmin(ctx contractapi.TransactionContextInterface) (*access.Caller, error) {
+	caller, err := requireOrgAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	holders, err := c.GetEncumbranceHolders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !holders.allowsMSP(caller.MSPID) {
+		return nil, fmt.Errorf("access denied: %s is not a registered encumbrance holder", caller.MSPID)
+	}
+	return caller, nil
+}
+
+// AddEncumbrance registers a mortgage or lien held by the caller's organization,
+// which must be a listed encumbrance holder, against a property. Dates are RFC 3339; endDate may be empty.
+func (c *PropertyContract) AddEncumbrance(ctx contractapi.TransactionContextInterface, propertyID string, encumbranceID string, encumbranceType string, amount float64, startDate string, endDate string) error {
+	caller, err := c.requireHolderAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if encumbranceType != encumbranceMortgage && encumbranceType != encumbranceLien {
+		return fmt.Errorf("unknown encumbrance type %q", encumbranceType)
+	}
+	if amount < 0 {
+		return fmt.Errorf("encumbrance amount cannot be negative")
+	}
+
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	if property.Status == statusRetired {
+		return fmt.Errorf("property %s is %s", propertyID, property.Status)
+	}
+
+	start, err := time.Parse(time.RFC3339, startDate)
+	if err != nil {
+		return fmt.Errorf("invalid start date: %v", err)
+	}
+	var end time.Time
+	if endDate != "" {
+		end, err = time.Parse(time.RFC3339, endDate)
+		if err != nil {
+			return fmt.Errorf("invalid end date: %v", err)
+		}
+		if !end.After(start) {
+			return fmt.Errorf("end date must be after start date")
+		}
+	}
+
+	key, err := encumbranceKey(ctx, propertyID, encumbranceID)
+	if err != nil {
+		return err
+	}
+	existingJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read encumbrance: %v", err)
+	}
+	if existingJSON != nil {
+		return fmt.Errorf("encumbrance %s already exists on property %s", encumbranceID, propertyID)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	encumbrance := &Encumbrance{
+		EncumbranceID: encumbranceID,
+		PropertyID:    propertyID,
+		Type:          encumbranceType,
+		HolderMSPID:   caller.MSPID,
+		RegisteredBy:  caller.ID,
+		Amount:        amount,
+		StartDate:     start,
+		EndDate:       end,
+		Status:        encumbranceActive,
+		CreatedAt:     timestamp,
+	}
+
+	return putEncumbrance(ctx, encumbrance)
+}
+
+// DischargeEncumbrance releases an encumbrance; only its holder organization may discharge it
+func (c *PropertyContract) DischargeEncumbrance(ctx contractapi.TransactionContextInterface, propertyID string, encumbranceID string) error {
+	caller, err := requireOrgAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	encumbrance, err := getEncumbrance(ctx, propertyID, encumbranceID)
+	if err != nil {
+		return err
+	}
+	if caller.MSPID != encumbrance.HolderMSPID {
+		return fmt.Errorf("access denied: encumbrance %s is held by %s", encumbranceID, encumbrance.HolderMSPID)
+	}
+	if encumbrance.Status != encumbranceActive {
+		return fmt.Errorf("encumbrance %s is already %s", encumbranceID, encumbrance.Status)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	encumbrance.Status = encumbranceDischarged
+	encumbrance.DischargedBy = caller.ID
+	encumbrance.DischargedAt = timestamp
+
+	return putEncumbrance(ctx, encumbrance)
+}
+
+// GetEncumbrances returns every encumbrance, active or discharged, registered against a property
+func (c *PropertyContract) GetEncumbrances(ctx contractapi.TransactionContextInterface, propertyID string) ([]*Encumbrance, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(encumbranceObjectType, []string{propertyID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	encumbrances := []*Encumbrance{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var encumbrance Encumbrance
+		err = json.Unmarshal(queryResponse.Value, &encumbrance)
+		if err != nil {
+			return nil, err
+		}
+		encumbrances = append(encumbrances, &encumbrance)
+	}
+
+	return encumbrances, nil
+}
+
+// getEncumbrance reads one encumbrance of a property
+func getEncumbrance(ctx contractapi.TransactionContextInterface, propertyID string, encumbranceID string) (*Encumbrance, error) {
+	key, err := encumbranceKey(ctx, propertyID, encumbranceID)
+	if err != nil {
+		return nil, err
+	}
+
+	encumbranceJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encumbrance: %v", err)
+	}
+	if encumbranceJSON == nil {
+		return nil, fmt.Errorf("encumbrance %s does not exist on property %s", encumbranceID, propertyID)
+	}
+
+	var encumbrance Encumbrance
+	err = json.Unmarshal(encumbranceJSON, &encumbrance)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encumbrance, nil
+}
+
+// putEncumbrance writes an encumbrance under its composite key
+func putEncumbrance(ctx contractapi.TransactionContextInterface, encumbrance *Encumbrance) error {
+	key, err := encumbranceKey(ctx, encumbrance.PropertyID, encumbrance.EncumbranceID)
+	if err != nil {
+		return err
+	}
+
+	encumbranceJSON, err := json.Marshal(encumbrance)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, encumbranceJSON)
+}
+
+// activeEncumbrances returns the IDs of a property's undischarged encumbrances.
+// An encumbrance past its end date still binds the title until its holder discharges it.
+func (c *PropertyContract) activeEncumbrances(ctx contractapi.TransactionContextInterface, propertyID string) ([]string, error) {
+	encumbrances, err := c.GetEncumbrances(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []string
+	for _, encumbrance := range encumbrances {
+		if encumbrance.Status == encumbranceActive {
+			active = append(active, encumbrance.EncumbranceID)
+		}
+	}
+	return active, nil
+}
+
+// requireUnencumbered rejects a title change while the property has an undischarged encumbrance
+func (c *PropertyContract) requireUnencumbered(ctx contractapi.TransactionContextInterface, propertyID string) error {
+	active, err := c.activeEncumbrances(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	if len(active) > 0 {
+		return newContractError(errCodePropertyEncumbered, "property %s has undischarged encumbrances %v", propertyID, active)
+	}
+	return nil
+}
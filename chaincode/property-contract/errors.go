@@ -0,0 +1,32 @@
+package main
+
+import "fmt"
+
+// Error codes carried in the message of a ContractError so that client gateways
+// can map them to HTTP statuses (e.g. INVALID_STATUS_TRANSITION -> 409)
+const (
+	errCodeInvalidStatus      = "INVALID_STATUS"
+	errCodeInvalidTransition  = "INVALID_STATUS_TRANSITION"
+	errCodeParcelOverlap      = "PARCEL_OVERLAP"
+	errCodePropertyEncumbered = "PROPERTY_ENCUMBERED"
+	errCodePropertyFrozen     = "PROPERTY_FROZEN"
+)
+
+// ContractError is an error with a stable machine-readable code
+type ContractError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error renders the error as "CODE: message"
+func (e *ContractError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// newContractError builds a ContractError with a formatted message
+func newContractError(code string, format string, args ...interface{}) error {
+	return &ContractError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// feeScheduleObjectType keys every published fee schedule by version
+const feeScheduleObjectType = "feeschedule"
+
+// feeScheduleName is the policy key attribute of the fee schedule in force
+const feeScheduleName = "fees"
+
+// defaultLocationTier applies to locations the schedule does not list
+const defaultLocationTier = "DEFAULT"
+
+// FeeRate is the stamp duty and registration fee charged on transfers of one
+// property type in one location tier. An empty PropertyType or LocationTier
+// matches any; the most specific rate wins.
+type FeeRate struct {
+	PropertyType        string  `json:"propertyType"`
+	LocationTier        string  `json:"locationTier"`
+	StampDutyRate       float64 `json:"stampDutyRate"`       // percent of the assessable value
+	RegistrationFeeRate float64 `json:"registrationFeeRate"` // percent of the assessable value
+	MinRegistrationFee  float64 `json:"minRegistrationFee"`
+	MaxRegistrationFee  float64 `json:"maxRegistrationFee"` // zero for no cap
+}
+
+// LocationTier assigns a location to a tier, e.g. METRO, URBAN or RURAL
+type LocationTier struct {
+	Location string `json:"location"`
+	Tier     string `json:"tier"`
+}
+
+// FeeSchedule is one published version of the transfer fee rules. Versions are
+// never overwritten, so every transaction can be traced to the rules it paid under.
+type FeeSchedule struct {
+	Version       int            `json:"version"`
+	Rates         []FeeRate      `json:"rates"`
+	LocationTiers []LocationTier `json:"locationTiers"`
+	PublishedBy   string         `json:"publishedBy"`
+	PublishedAt   time.Time      `json:"publishedAt"`
+}
+
+// TransferFeeEstimate is the duty and fees owed on transferring a property for an amount
+type TransferFeeEstimate struct {
+	PropertyID          string  `json:"propertyId"`
+	PropertyType        string  `json:"propertyType"`
+	LocationTier        string  `json:"locationTier"`
+	DeclaredValue       float64 `json:"declaredValue"`
+	GuidelineValue      float64 `json:"guidelineValue"`  // zero when no guideline rate applies
+	AssessableValue     float64 `json:"assessableValue"` // the higher of the declared and guideline values
+	BelowGuideline      bool    `json:"belowGuideline"`
+	StampDutyRate       float64 `json:"stampDutyRate"`
+	StampDuty           float64 `json:"stampDuty"`
+	RegistrationFeeRate float64 `json:"registrationFeeRate"`
+	RegistrationFee     float64 `json:"registrationFee"`
+	TotalFees           float64 `json:"totalFees"`
+	FeeScheduleVersion  int     `json:"feeScheduleVersion"` // zero when no schedule has been published
+}
+
+// tierOf returns the tier of a location, compared case-insensitively
+func (s *FeeSchedule) tierOf(location string) string {
+	location = normalizeLocation(location)
+	for _, tier := range s.LocationTiers {
+		if normalizeLocation(tier.Location) == location {
+			return tier.Tier
+		}
+	}
+	return defaultLocationTier
+}
+
+// rateFor returns the most specific rate for a property type and tier, or nil
+func (s *FeeSchedule) rateFor(propertyType string, tier string) *FeeRate {
+	var best *FeeRate
+	bestScore := -1
+	for i := range s.Rates {
+		rate := &s.Rates[i]
+		if rate.PropertyType != "" && rate.PropertyType != propertyType {
+			continue
+		}
+		if rate.LocationTier != "" && rate.LocationTier != tier {
+			continue
+		}
+
+		// An exact property type outranks an exact tier
+		score := 0
+		if rate.PropertyType != "" {
+			score += 2
+		}
+		if rate.LocationTier != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = rate, score
+		}
+	}
+	return best
+}
+
+// registrationFee returns the registration fee on an assessable value, clamped
+// to the rate's minimum and, when set, its maximum
+func (r *FeeRate) registrationFee(assessableValue float64) float64 {
+	fee := math.Max(assessableValue*r.RegistrationFeeRate/100, r.MinRegistrationFee)
+	if r.MaxRegistrationFee > 0 {
+		fee = math.Min(fee, r.MaxRegistrationFee)
+	}
+	return fee
+}
+
+// validateFeeRates rejects negative rates and inverted fee bounds
+func validateFeeRates(rates []FeeRate) error {
+	if len(rates) == 0 {
+		return fmt.Errorf("at least one fee rate is required")
+	}
+	for _, rate := range rates {
+		if rate.StampDutyRate < 0 || rate.RegistrationFeeRate < 0 || rate.MinRegistrationFee < 0 || rate.MaxRegistrationFee < 0 {
+			return fmt.Errorf("fee rates and bounds cannot be negative")
+		}
+		if rate.MaxRegistrationFee > 0 && rate.MaxRegistrationFee < rate.MinRegistrationFee {
+			return fmt.Errorf("maximum registration fee %.2f is below the minimum %.2f", rate.MaxRegistrationFee, rate.MinRegistrationFee)
+		}
+	}
+	return nil
+}
+
+// PublishFeeSchedule publishes a new version of the fee schedule from
+// {"rates": [...], "locationTiers": [...]}. Earlier versions stay on the ledger.
+// Registrar admin only.
+func (c *PropertyContract) PublishFeeSchedule(ctx contractapi.TransactionContextInterface, scheduleJSON string) (int, error) {
+	caller, err := access.RequireRegistrarAdmin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var schedule FeeSchedule
+	err = json.Unmarshal([]byte(scheduleJSON), &schedule)
+	if err != nil {
+		return 0, fmt.Errorf("invalid fee schedule: %v", err)
+	}
+	if err := validateFeeRates(schedule.Rates); err != nil {
+		return 0, err
+	}
+	if schedule.LocationTiers == nil {
+		schedule.LocationTiers = []LocationTier{}
+	}
+
+	current, err := currentFeeSchedule(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	schedule.Version = 1
+	if current != nil {
+		schedule.Version = current.Version + 1
+	}
+	schedule.PublishedBy = caller.ID
+	schedule.PublishedAt = time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	scheduleBytes, err := json.Marshal(&schedule)
+	if err != nil {
+		return 0, err
+	}
+
+	versionKey, err := feeScheduleKey(ctx, schedule.Version)
+	if err != nil {
+		return 0, err
+	}
+	if err := ctx.GetStub().PutState(versionKey, scheduleBytes); err != nil {
+		return 0, err
+	}
+
+	currentKey, err := ctx.GetStub().CreateCompositeKey(policyObjectType, []string{feeScheduleName})
+	if err != nil {
+		return 0, err
+	}
+	if err := ctx.GetStub().PutState(currentKey, scheduleBytes); err != nil {
+		return 0, err
+	}
+
+	return schedule.Version, nil
+}
+
+// GetFeeSchedule returns the fee schedule in force
+func (c *PropertyContract) GetFeeSchedule(ctx contractapi.TransactionContextInterface) (*FeeSchedule, error) {
+	schedule, err := currentFeeSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return nil, fmt.Errorf("no fee schedule has been published")
+	}
+	return schedule, nil
+}
+
+// GetFeeScheduleVersion returns a published version of the fee schedule
+func (c *PropertyContract) GetFeeScheduleVersion(ctx contractapi.TransactionContextInterface, version int) (*FeeSchedule, error) {
+	key, err := feeScheduleKey(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduleJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fee schedule: %v", err)
+	}
+	if scheduleJSON == nil {
+		return nil, fmt.Errorf("fee schedule version %d does not exist", version)
+	}
+
+	var schedule FeeSchedule
+	err = json.Unmarshal(scheduleJSON, &schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// EstimateTransferFees returns the stamp duty and registration fee owed on
+// transferring a property for amount under the fee schedule in force. Duty is
+// assessed on the guideline value when the amount declared is lower.
+func (c *PropertyContract) EstimateTransferFees(ctx contractapi.TransactionContextInterface, propertyID string, amount float64) (*TransferFeeEstimate, error) {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	return c.transferFees(ctx, property, amount, 100)
+}
+
+// transferFees computes the fees owed on transferring share percent of a
+// property for amount, assessed against the guideline value of that share.
+// Without a published schedule nothing is owed.
+func (c *PropertyContract) transferFees(ctx contractapi.TransactionContextInterface, property *Property, amount float64, share float64) (*TransferFeeEstimate, error) {
+	if amount < 0 {
+		return nil, fmt.Errorf("transfer amount cannot be negative")
+	}
+
+	guideline, err := c.checkGuideline(ctx, property, amount, share)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &TransferFeeEstimate{
+		PropertyID:      property.PropertyID,
+		PropertyType:    property.PropertyType,
+		LocationTier:    defaultLocationTier,
+		DeclaredValue:   amount,
+		GuidelineValue:  guideline.GuidelineValue,
+		AssessableValue: math.Max(amount, guideline.GuidelineValue),
+		BelowGuideline:  guideline.BelowGuideline,
+	}
+
+	schedule, err := currentFeeSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return estimate, nil
+	}
+
+	estimate.FeeScheduleVersion = schedule.Version
+	estimate.LocationTier = schedule.tierOf(property.Location)
+
+	rate := schedule.rateFor(property.PropertyType, estimate.LocationTier)
+	if rate == nil {
+		return nil, fmt.Errorf("fee schedule version %d has no rate for %s properties in tier %s", schedule.Version, property.PropertyType, estimate.LocationTier)
+	}
+
+	estimate.StampDutyRate = rate.StampDutyRate
+	estimate.StampDuty = roundCurrency(estimate.AssessableValue * rate.StampDutyRate / 100)
+	estimate.RegistrationFeeRate = rate.RegistrationFeeRate
+	estimate.RegistrationFee = roundCurrency(rate.registrationFee(estimate.AssessableValue))
+	estimate.TotalFees = estimate.StampDuty + estimate.RegistrationFee
+
+	return estimate, nil
+}
+
+// roundCurrency rounds an amount to two decimal places
+func roundCurrency(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// feeScheduleKey returns the ledger key of a fee schedule version, zero-padded
+// so versions list in order
+func feeScheduleKey(ctx contractapi.TransactionContextInterface, version int) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(feeScheduleObjectType, []string{fmt.Sprintf("%08d", version)})
+}
+
+// currentFeeSchedule returns the fee schedule in force, or nil if none has been published
+func currentFeeSchedule(ctx contractapi.TransactionContextInterface) (*FeeSchedule, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(policyObjectType, []string{feeScheduleName})
+	if err != nil {
+		return nil, err
+	}
+
+	scheduleJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fee schedule: %v", err)
+	}
+	if scheduleJSON == nil {
+		return nil, nil
+	}
+
+	var schedule FeeSchedule
+	err = json.Unmarshal(scheduleJSON, &schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
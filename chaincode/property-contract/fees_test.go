@@ -0,0 +1,118 @@
+package main
+
+import "testing"
+
+func TestRateForPrefersTheMostSpecificRate(t *testing.T) {
+	schedule := &FeeSchedule{Rates: []FeeRate{
+		{StampDutyRate: 5},
+		{LocationTier: "METRO", StampDutyRate: 6},
+		{PropertyType: "COMMERCIAL", StampDutyRate: 7},
+		{PropertyType: "COMMERCIAL", LocationTier: "METRO", StampDutyRate: 8},
+	}}
+
+	tests := []struct {
+		name         string
+		propertyType string
+		tier         string
+		want         float64
+	}{
+		{"type and tier", "COMMERCIAL", "METRO", 8},
+		{"type outranks tier", "COMMERCIAL", "RURAL", 7},
+		{"tier only", "RESIDENTIAL", "METRO", 6},
+		{"catch-all", "RESIDENTIAL", "RURAL", 5},
+		{"default tier", "AGRICULTURAL", defaultLocationTier, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate := schedule.rateFor(tt.propertyType, tt.tier)
+			if rate == nil || rate.StampDutyRate != tt.want {
+				t.Fatalf("rateFor(%s, %s) = %+v, want the %v%% rate", tt.propertyType, tt.tier, rate, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateForPrefersTypeOverTierRegardlessOfOrder(t *testing.T) {
+	schedule := &FeeSchedule{Rates: []FeeRate{
+		{PropertyType: "RESIDENTIAL", StampDutyRate: 7},
+		{LocationTier: "METRO", StampDutyRate: 6},
+	}}
+	if rate := schedule.rateFor("RESIDENTIAL", "METRO"); rate == nil || rate.StampDutyRate != 7 {
+		t.Fatalf("rateFor = %+v, want the RESIDENTIAL rate", rate)
+	}
+}
+
+func TestRateForWithoutMatch(t *testing.T) {
+	schedule := &FeeSchedule{Rates: []FeeRate{{PropertyType: "COMMERCIAL", LocationTier: "METRO", StampDutyRate: 8}}}
+	if rate := schedule.rateFor("RESIDENTIAL", "METRO"); rate != nil {
+		t.Fatalf("rateFor = %+v, want nil", rate)
+	}
+}
+
+func TestTierOf(t *testing.T) {
+	schedule := &FeeSchedule{LocationTiers: []LocationTier{{Location: "Bengaluru", Tier: "METRO"}}}
+
+	tests := []struct {
+		location string
+		want     string
+	}{
+		{"Bengaluru", "METRO"},
+		{"  bengaluru ", "METRO"},
+		{"Mysuru", defaultLocationTier},
+	}
+
+	for _, tt := range tests {
+		if got := schedule.tierOf(tt.location); got != tt.want {
+			t.Errorf("tierOf(%q) = %s, want %s", tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestRegistrationFeeClamping(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  FeeRate
+		value float64
+		want  float64
+	}{
+		{"within bounds", FeeRate{RegistrationFeeRate: 1, MinRegistrationFee: 100, MaxRegistrationFee: 30000}, 1000000, 10000},
+		{"raised to the minimum", FeeRate{RegistrationFeeRate: 1, MinRegistrationFee: 100, MaxRegistrationFee: 30000}, 5000, 100},
+		{"capped at the maximum", FeeRate{RegistrationFeeRate: 1, MinRegistrationFee: 100, MaxRegistrationFee: 30000}, 10000000, 30000},
+		{"no cap", FeeRate{RegistrationFeeRate: 1, MinRegistrationFee: 100}, 10000000, 100000},
+		{"minimum on a gift", FeeRate{RegistrationFeeRate: 1, MinRegistrationFee: 100}, 0, 100},
+		{"no fee", FeeRate{}, 1000000, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rate.registrationFee(tt.value); got != tt.want {
+				t.Fatalf("registrationFee(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFeeRates(t *testing.T) {
+	tests := []struct {
+		name    string
+		rates   []FeeRate
+		wantErr bool
+	}{
+		{"valid", []FeeRate{{StampDutyRate: 5, RegistrationFeeRate: 1, MinRegistrationFee: 100, MaxRegistrationFee: 30000}}, false},
+		{"uncapped", []FeeRate{{StampDutyRate: 5, RegistrationFeeRate: 1, MinRegistrationFee: 100}}, false},
+		{"no rates", nil, true},
+		{"negative duty", []FeeRate{{StampDutyRate: -1}}, true},
+		{"negative minimum", []FeeRate{{MinRegistrationFee: -1}}, true},
+		{"cap below minimum", []FeeRate{{MinRegistrationFee: 500, MaxRegistrationFee: 100}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFeeRates(tt.rates)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateFeeRates = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
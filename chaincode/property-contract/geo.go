@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// geoObjectType indexes properties by geohash. Each geohash character is a
+	// separate key attribute, so a partial composite key query on the first n
+	// characters returns every property inside that precision-n cell.
+	geoObjectType = "geo"
+
+	// geohashPrecision is the stored geohash length (cells of roughly 5m x 5m)
+	geohashPrecision = 9
+
+	// maxCoverCells bounds how many cells a single area query may scan
+	maxCoverCells = 16
+
+	earthRadiusKm = 6371.0
+	kmPerDegree   = 111.32
+)
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// encodeGeohash returns the geohash of a point at the given precision
+func encodeGeohash(latitude float64, longitude float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	hash := make([]byte, 0, precision)
+	bit, index := 0, 0
+	evenBit := true
+	for len(hash) < precision {
+		if evenBit {
+			mid := (lonRange[0] + lonRange[1]) / 2
+			if longitude >= mid {
+				index = index*2 + 1
+				lonRange[0] = mid
+			} else {
+				index = index * 2
+				lonRange[1] = mid
+			}
+		} else {
+			mid := (latRange[0] + latRange[1]) / 2
+			if latitude >= mid {
+				index = index*2 + 1
+				latRange[0] = mid
+			} else {
+				index = index * 2
+				latRange[1] = mid
+			}
+		}
+		evenBit = !evenBit
+
+		bit++
+		if bit == 5 {
+			hash = append(hash, geohashAlphabet[index])
+			bit, index = 0, 0
+		}
+	}
+
+	return string(hash)
+}
+
+// geohashCellSize returns the height and width in degrees of a cell at a precision
+func geohashCellSize(precision int) (float64, float64) {
+	bits := 5 * precision
+	lonBits := (bits + 1) / 2
+	latBits := bits / 2
+	return 180 / math.Pow(2, float64(latBits)), 360 / math.Pow(2, float64(lonBits))
+}
+
+// coverBoundingBox returns the geohash cells covering a box, at the finest
+// precision that needs no more than maxCoverCells cells
+func coverBoundingBox(minLat float64, minLon float64, maxLat float64, maxLon float64) []string {
+	for precision := geohashPrecision; precision > 1; precision-- {
+		cellHeight, cellWidth := geohashCellSize(precision)
+		rows := math.Floor((maxLat+90)/cellHeight) - math.Floor((minLat+90)/cellHeight) + 1
+		cols := math.Floor((maxLon+180)/cellWidth) - math.Floor((minLon+180)/cellWidth) + 1
+		if rows*cols <= maxCoverCells {
+			return cellsAtPrecision(minLat, minLon, maxLat, maxLon, precision)
+		}
+	}
+
+	return []string{""}
+}
+
+// cellsAtPrecision returns every geohash cell of a precision that intersects a box
+func cellsAtPrecision(minLat float64, minLon float64, maxLat float64, maxLon float64, precision int) []string {
+	cellHeight, cellWidth := geohashCellSize(precision)
+	firstRow := math.Floor((minLat + 90) / cellHeight)
+	lastRow := math.Floor((maxLat + 90) / cellHeight)
+	firstCol := math.Floor((minLon + 180) / cellWidth)
+	lastCol := math.Floor((maxLon + 180) / cellWidth)
+
+	var cells []string
+	for row := firstRow; row <= lastRow; row++ {
+		for col := firstCol; col <= lastCol; col++ {
+			centerLat := math.Min(-90+(row+0.5)*cellHeight, 90)
+			centerLon := math.Min(-180+(col+0.5)*cellWidth, 180)
+			cells = append(cells, encodeGeohash(centerLat, centerLon, precision))
+		}
+	}
+	return cells
+}
+
+// haversineKm returns the great-circle distance between two points in kilometres
+func haversineKm(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// validateCoordinates checks that a point lies on the globe
+func validateCoordinates(latitude float64, longitude float64) error {
+	if latitude < -90 || latitude > 90 {
+		return fmt.Errorf("latitude %f is out of range", latitude)
+	}
+	if longitude < -180 || longitude > 180 {
+		return fmt.Errorf("longitude %f is out of range", longitude)
+	}
+	return nil
+}
+
+// geoIndexAttributes splits a geohash into per-character key attributes
+func geoIndexAttributes(geohash string) []string {
+	attributes := make([]string, 0, len(geohash)+1)
+	for _, char := range geohash {
+		attributes = append(attributes, string(char))
+	}
+	return attributes
+}
+
+// putGeoIndex sets a property's geohash and writes its geo index entry
+func putGeoIndex(ctx contractapi.TransactionContextInterface, property *Property) error {
+	property.Geohash = encodeGeohash(property.Latitude, property.Longitude, geohashPrecision)
+
+	key, err := ctx.GetStub().CreateCompositeKey(geoObjectType, append(geoIndexAttributes(property.Geohash), property.PropertyID))
+	if err != nil {
+		return err
+	}
+
+	// Index entries carry no data; a single byte is stored because an empty value deletes the key
+	return ctx.GetStub().PutState(key, []byte{0x00})
+}
+
+// propertiesInCells returns the properties indexed inside any of the geohash cells
+func (c *PropertyContract) propertiesInCells(ctx contractapi.TransactionContextInterface, cells []string) ([]*Property, error) {
+	var properties []*Property
+	seen := map[string]bool{}
+	for _, cell := range cells {
+		resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(geoObjectType, geoIndexAttributes(cell))
+		if err != nil {
+			return nil, err
+		}
+
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+
+			_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+			propertyID := attributes[len(attributes)-1]
+			if seen[propertyID] {
+				continue
+			}
+			seen[propertyID] = true
+
+			property, err := c.GetProperty(ctx, propertyID)
+			if err != nil {
+				resultsIterator.Close()
+				return nil, err
+			}
+			properties = append(properties, property)
+		}
+		resultsIterator.Close()
+	}
+
+	return properties, nil
+}
+
+// GetPropertiesNear retrieves properties within radiusKm of a point, nearest first
+func (c *PropertyContract) GetPropertiesNear(ctx contractapi.TransactionContextInterface, latitude float64, longitude float64, radiusKm float64) ([]*Property, error) {
+	if err := validateCoordinates(latitude, longitude); err != nil {
+		return nil, err
+	}
+	if radiusKm <= 0 {
+		return nil, fmt.Errorf("radius must be positive, got %f", radiusKm)
+	}
+
+	latDelta := radiusKm / kmPerDegree
+	lonDelta := 180.0
+	if cosLat := math.Cos(latitude * math.Pi / 180); cosLat > 0.01 {
+		lonDelta = math.Min(radiusKm/(kmPerDegree*cosLat), 180)
+	}
+
+	cells := coverBoundingBox(
+		math.Max(latitude-latDelta, -90), math.Max(longitude-lonDelta, -180),
+		math.Min(latitude+latDelta, 90), math.Min(longitude+lonDelta, 180),
+	)
+	candidates, err := c.propertiesInCells(ctx, cells)
+	if err != nil {
+		return nil, err
+	}
+
+	distances := map[string]float64{}
+	var properties []*Property
+	for _, property := range candidates {
+		distance := haversineKm(latitude, longitude, property.Latitude, property.Longitude)
+		if distance <= radiusKm {
+			distances[property.PropertyID] = distance
+			properties = append(properties, property)
+		}
+	}
+	sort.SliceStable(properties, func(i, j int) bool {
+		return distances[properties[i].PropertyID] < distances[properties[j].PropertyID]
+	})
+
+	return properties, nil
+}
+
+// GetPropertiesInBoundingBox retrieves properties whose coordinates lie inside a box
+func (c *PropertyContract) GetPropertiesInBoundingBox(ctx contractapi.TransactionContextInterface, minLatitude float64, minLongitude float64, maxLatitude float64, maxLongitude float64) ([]*Property, error) {
+	if err := validateCoordinates(minLatitude, minLongitude); err != nil {
+		return nil, err
+	}
+	if err := validateCoordinates(maxLatitude, maxLongitude); err != nil {
+		return nil, err
+	}
+	if minLatitude > maxLatitude || minLongitude > maxLongitude {
+		return nil, fmt.Errorf("bounding box minimum must not exceed its maximum")
+	}
+
+	candidates, err := c.propertiesInCells(ctx, coverBoundingBox(minLatitude, minLongitude, maxLatitude, maxLongitude))
+	if err != nil {
+		return nil, err
+	}
+
+	var properties []*Property
+	for _, property := range candidates {
+		if property.Latitude >= minLatitude && property.Latitude <= maxLatitude &&
+			property.Longitude >= minLongitude && property.Longitude <= maxLongitude {
+			properties = append(properties, property)
+		}
+	}
+
+	return properties, nil
+}
+
+// RebuildGeoIndex computes the geohash of every property and writes its geo
+// index entry, for properties registered before geohashes were stored
+func (c *PropertyContract) RebuildGeoIndex(ctx contractapi.TransactionContextInterface) (int, error) {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return 0, err
+	}
+
+	properties, err := c.allProperties(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	indexed := 0
+	for _, property := range properties {
+		if property.Geohash != "" {
+			continue
+		}
+		if err := putGeoIndex(ctx, property); err != nil {
+			return indexed, err
+		}
+		if err := c.putProperty(ctx, property); err != nil {
+			return indexed, err
+		}
+		indexed++
+	}
+
+	return indexed, nil
+}
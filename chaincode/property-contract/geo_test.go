@@ -0,0 +1,118 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeGeohash(t *testing.T) {
+	tests := []struct {
+		latitude  float64
+		longitude float64
+		precision int
+		want      string
+	}{
+		{42.605, -5.603, 5, "ezs42"},
+		{57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{0, 0, 1, "s"},
+		{-90, -180, 3, "000"},
+		{90, 180, 3, "zzz"},
+	}
+
+	for _, tt := range tests {
+		if got := encodeGeohash(tt.latitude, tt.longitude, tt.precision); got != tt.want {
+			t.Errorf("encodeGeohash(%v, %v, %d) = %q, want %q", tt.latitude, tt.longitude, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeGeohashPrefixesCoarserCells(t *testing.T) {
+	fine := encodeGeohash(12.9716, 77.5946, geohashPrecision)
+	for precision := 1; precision < geohashPrecision; precision++ {
+		if coarse := encodeGeohash(12.9716, 77.5946, precision); !strings.HasPrefix(fine, coarse) {
+			t.Errorf("precision %d geohash %q is not a prefix of %q", precision, coarse, fine)
+		}
+	}
+}
+
+func TestCellsAtPrecisionCoversNeighbours(t *testing.T) {
+	const precision = 6
+	cellHeight, cellWidth := geohashCellSize(precision)
+	latitude, longitude := 12.9716, 77.5946
+
+	// A box one cell wider than the centre cell in every direction
+	cells := cellsAtPrecision(latitude-cellHeight, longitude-cellWidth, latitude+cellHeight, longitude+cellWidth, precision)
+
+	want := map[string]bool{}
+	for _, dLat := range []float64{-cellHeight, 0, cellHeight} {
+		for _, dLon := range []float64{-cellWidth, 0, cellWidth} {
+			want[encodeGeohash(latitude+dLat, longitude+dLon, precision)] = true
+		}
+	}
+	if len(want) != 9 {
+		t.Fatalf("expected 9 distinct neighbour cells, got %d", len(want))
+	}
+
+	got := map[string]bool{}
+	for _, cell := range cells {
+		got[cell] = true
+	}
+	if len(cells) != 9 || len(got) != 9 {
+		t.Fatalf("cellsAtPrecision = %v, want the 9 cells around %s", cells, encodeGeohash(latitude, longitude, precision))
+	}
+	for cell := range want {
+		if !got[cell] {
+			t.Errorf("neighbour %s missing from %v", cell, cells)
+		}
+	}
+}
+
+func TestCoverBoundingBox(t *testing.T) {
+	tests := []struct {
+		name                           string
+		minLat, minLon, maxLat, maxLon float64
+	}{
+		{"single point", 12.9716, 77.5946, 12.9716, 77.5946},
+		{"city block", 12.97, 77.59, 12.975, 77.60},
+		{"city", 12.8, 77.4, 13.1, 77.8},
+		{"across the equator and meridian", -0.5, -0.5, 0.5, 0.5},
+		{"whole globe", -90, -180, 90, 180},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cells := coverBoundingBox(tt.minLat, tt.minLon, tt.maxLat, tt.maxLon)
+			if len(cells) == 0 || len(cells) > maxCoverCells {
+				t.Fatalf("coverBoundingBox returned %d cells, want 1..%d", len(cells), maxCoverCells)
+			}
+
+			// Every corner and the centre must fall inside one of the cells
+			points := [][2]float64{
+				{tt.minLat, tt.minLon}, {tt.minLat, tt.maxLon},
+				{tt.maxLat, tt.minLon}, {tt.maxLat, tt.maxLon},
+				{(tt.minLat + tt.maxLat) / 2, (tt.minLon + tt.maxLon) / 2},
+			}
+			for _, p := range points {
+				hash := encodeGeohash(p[0], p[1], geohashPrecision)
+				covered := false
+				for _, cell := range cells {
+					if strings.HasPrefix(hash, cell) {
+						covered = true
+					}
+				}
+				if !covered {
+					t.Errorf("point %v (%s) is outside cells %v", p, hash, cells)
+				}
+			}
+		})
+	}
+}
+
+func TestGeoIndexAttributes(t *testing.T) {
+	got := geoIndexAttributes("tdr1y")
+	want := []string{"t", "d", "r", "1", "y"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("geoIndexAttributes = %v, want %v", got, want)
+	}
+}
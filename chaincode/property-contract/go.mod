@@ -2,7 +2,13 @@ module property-contract
 
 go 1.21
 
-require github.com/hyperledger/fabric-contract-api-go v1.2.1
+require (
+	access v0.0.0
+	couchquery v0.0.0
+	github.com/hyperledger/fabric-chaincode-go v0.0.0-20230228194215-b84622ba6a7a
+	github.com/hyperledger/fabric-contract-api-go v1.2.1
+	github.com/hyperledger/fabric-protos-go v0.3.0
+)
 
 require (
 	github.com/go-openapi/jsonpointer v0.19.5 // indirect
@@ -13,8 +19,6 @@ require (
 	github.com/gobuffalo/packd v1.0.1 // indirect
 	github.com/gobuffalo/packr v1.30.1 // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
-	github.com/hyperledger/fabric-chaincode-go v0.0.0-20230228194215-b84622ba6a7a // indirect
-	github.com/hyperledger/fabric-protos-go v0.3.0 // indirect
 	github.com/joho/godotenv v1.4.0 // indirect
 	github.com/josharian/intern v1.0.0 // indirect
 	github.com/mailru/easyjson v0.7.7 // indirect
@@ -30,3 +34,9 @@ require (
 	google.golang.org/protobuf v1.28.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
+
+// access and couchquery are shared by the chaincodes; vendor them (go mod vendor) before packaging
+replace (
+	access => ../access
+	couchquery => ../couchquery
+)
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Object types namespacing every entity stored by this contract
+const (
+	propertyObjectType    = "property"
+	userObjectType        = "user"
+	transactionObjectType = "txn"
+)
+
+// Key prefixes used before entities were namespaced with composite keys
+const (
+	legacyUserPrefix        = "USER_"
+	legacyTransactionPrefix = "TXN_"
+)
+
+// propertyKey returns the composite ledger key of a property
+func propertyKey(ctx contractapi.TransactionContextInterface, propertyID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(propertyObjectType, []string{propertyID})
+}
+
+// userKey returns the composite ledger key of a user
+func userKey(ctx contractapi.TransactionContextInterface, userID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(userObjectType, []string{userID})
+}
+
+// transactionKey returns the composite ledger key of a transaction record
+func transactionKey(ctx contractapi.TransactionContextInterface, transactionID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(transactionObjectType, []string{transactionID})
+}
+
+// hasObjectType reports whether a ledger key belongs to the given object type.
+// Rich queries scan every document in the namespace, so results are filtered by key.
+func hasObjectType(ctx contractapi.TransactionContextInterface, key string, objectType string) bool {
+	keyType, _, err := ctx.GetStub().SplitCompositeKey(key)
+	return err == nil && keyType == objectType
+}
+
+// propertySelector constrains a CouchDB selector to property documents, so
+// pages and fetched counts only ever include properties
+func propertySelector(selector map[string]interface{}) map[string]interface{} {
+	selector["docType"] = propertyObjectType
+	return selector
+}
+
+// transactionSelector constrains a CouchDB selector to transaction records
+func transactionSelector(selector map[string]interface{}) map[string]interface{} {
+	selector["docType"] = transactionObjectType
+	return selector
+}
+
+// putProperty writes a property under its composite key
+func (c *PropertyContract) putProperty(ctx contractapi.TransactionContextInterface, property *Property) error {
+	key, err := propertyKey(ctx, property.PropertyID)
+	if err != nil {
+		return err
+	}
+
+	property.DocType = propertyObjectType
+	property.RegisteredAtKey = sortableTimestamp(property.RegisteredAt)
+
+	propertyJSON, err := json.Marshal(property)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, propertyJSON)
+}
+
+// MigrateKeys rewrites entities stored under legacy plain keys (propertyID,
+// USER_<id>, TXN_<id>) to their composite keys and returns how many were moved.
+// It only sees plain keys, so running it again after a migration is a no-op.
+func (c *PropertyContract) MigrateKeys(ctx contractapi.TransactionContextInterface) (int, error) {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return 0, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return migrated, err
+		}
+
+		var newKey string
+		switch {
+		case strings.HasPrefix(queryResponse.Key, legacyUserPrefix):
+			newKey, err = userKey(ctx, strings.TrimPrefix(queryResponse.Key, legacyUserPrefix))
+		case strings.HasPrefix(queryResponse.Key, legacyTransactionPrefix):
+			newKey, err = transactionKey(ctx, strings.TrimPrefix(queryResponse.Key, legacyTransactionPrefix))
+		default:
+			newKey, err = propertyKey(ctx, queryResponse.Key)
+		}
+		if err != nil {
+			return migrated, fmt.Errorf("failed to create key for %s: %v", queryResponse.Key, err)
+		}
+
+		err = ctx.GetStub().PutState(newKey, queryResponse.Value)
+		if err != nil {
+			return migrated, err
+		}
+		err = ctx.GetStub().DelState(queryResponse.Key)
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// BackfillDocTypes stamps the docType field on properties and transactions, the
+// registeredAtKey time on properties and the recordedAt time on transactions,
+// written before they existed, so rich queries find and sort them, and returns how many were updated. Transactions still
+// under legacy TXN_ keys are moved to their composite keys on the way. Run it
+// after MigrateKeys; running it again is a no-op.
+func (c *PropertyContract) BackfillDocTypes(ctx contractapi.TransactionContextInterface) (int, error) {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return 0, err
+	}
+
+	properties, err := c.allProperties(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, property := range properties {
+		if property.DocType == propertyObjectType && property.RegisteredAtKey != "" {
+			continue
+		}
+		if err := c.putProperty(ctx, property); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	transactions, err := backfillTransactions(ctx)
+	return updated + transactions, err
+}
+
+// backfillTransactions stamps docType and recordedAt on namespaced transaction
+// records and moves records still under legacy TXN_ keys to their composite
+// keys, which rich queries would otherwise never match
+func backfillTransactions(ctx contractapi.TransactionContextInterface) (int, error) {
+	updated := 0
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(transactionObjectType, []string{})
+	if err != nil {
+		return updated, err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return updated, err
+		}
+
+		var transaction Transaction
+		err = json.Unmarshal(queryResponse.Value, &transaction)
+		if err != nil {
+			return updated, err
+		}
+		if transaction.DocType == transactionObjectType && transaction.RecordedAt != "" {
+			continue
+		}
+		if err := putTransaction(ctx, &transaction); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	legacyIterator, err := ctx.GetStub().GetStateByRange(legacyTransactionPrefix, prefixRangeEnd(legacyTransactionPrefix))
+	if err != nil {
+		return updated, err
+	}
+	defer legacyIterator.Close()
+
+	for legacyIterator.HasNext() {
+		queryResponse, err := legacyIterator.Next()
+		if err != nil {
+			return updated, err
+		}
+
+		var transaction Transaction
+		err = json.Unmarshal(queryResponse.Value, &transaction)
+		if err != nil {
+			return updated, err
+		}
+		if err := putTransaction(ctx, &transaction); err != nil {
+			return updated, err
+		}
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	return updated, nil
+}
+
+// prefixRangeEnd returns the exclusive end key of a range scan over every key
+// starting with prefix
+func prefixRangeEnd(prefix string) string {
+	return prefix[:len(prefix)-1] + string(prefix[len(prefix)-1]+1)
+}
+
+// QualifyOwnerIDs rewrites the owner IDs of properties recorded before user IDs
+// were qualified with an MSP ID, so their owners can sign for them again, and
+// returns how many properties were updated. mappingJSON maps bare IDs to their
+// MSPID::enrollmentID; bare IDs it leaves out are qualified with defaultMSPID.
+// Running it again is a no-op.
+func (c *PropertyContract) QualifyOwnerIDs(ctx contractapi.TransactionContextInterface, defaultMSPID string, mappingJSON string) (int, error) {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return 0, err
+	}
+
+	mapping := map[string]string{}
+	if mappingJSON != "" {
+		if err := json.Unmarshal([]byte(mappingJSON), &mapping); err != nil {
+			return 0, fmt.Errorf("failed to parse owner ID mapping: %v", err)
+		}
+	}
+	for bareID, userID := range mapping {
+		if err := access.ValidateIdentityID(userID); err != nil {
+			return 0, fmt.Errorf("mapping for %q: %v", bareID, err)
+		}
+	}
+
+	properties, err := c.allProperties(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, property := range properties {
+		changed, err := qualifyOwners(property, defaultMSPID, mapping)
+		if err != nil {
+			return updated, fmt.Errorf("property %s: %v", property.PropertyID, err)
+		}
+		if !changed {
+			continue
+		}
+		if err := c.putProperty(ctx, property); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	return updated, nil
+}
+
+// qualifyOwners rewrites the bare IDs in a property's Owner and Owners through
+// the mapping, falling back to defaultMSPID, and reports whether any changed
+func qualifyOwners(property *Property, defaultMSPID string, mapping map[string]string) (bool, error) {
+	qualify := func(userID string) (string, error) {
+		if userID == "" || access.IsIdentityID(userID) {
+			return userID, nil
+		}
+		if qualified, ok := mapping[userID]; ok {
+			return qualified, nil
+		}
+		if defaultMSPID == "" {
+			return "", fmt.Errorf("owner ID %q is not qualified and has no mapping", userID)
+		}
+		return access.IdentityID(defaultMSPID, userID), nil
+	}
+
+	changed := false
+	owner, err := qualify(property.Owner)
+	if err != nil {
+		return false, err
+	}
+	if owner != property.Owner {
+		property.Owner = owner
+		changed = true
+	}
+	for i := range property.Owners {
+		ownerID, err := qualify(property.Owners[i].OwnerID)
+		if err != nil {
+			return false, err
+		}
+		if ownerID != property.Owners[i].OwnerID {
+			property.Owners[i].OwnerID = ownerID
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQualifyOwners(t *testing.T) {
+	mapping := map[string]string{"bob": "Org2MSP::bob"}
+
+	tests := []struct {
+		name         string
+		property     Property
+		defaultMSPID string
+		want         Property
+		changed      bool
+		wantErr      bool
+	}{
+		{
+			name:         "sole owner qualified with the default MSP",
+			property:     Property{Owner: "alice"},
+			defaultMSPID: "Org1MSP",
+			want:         Property{Owner: "Org1MSP::alice"},
+			changed:      true,
+		},
+		{
+			name: "co-owners mapped or qualified",
+			property: Property{Owner: "bob", Owners: []OwnershipShare{
+				{OwnerID: "bob", Share: 60},
+				{OwnerID: "carol", Share: 40},
+			}},
+			defaultMSPID: "Org1MSP",
+			want: Property{Owner: "Org2MSP::bob", Owners: []OwnershipShare{
+				{OwnerID: "Org2MSP::bob", Share: 60},
+				{OwnerID: "Org1MSP::carol", Share: 40},
+			}},
+			changed: true,
+		},
+		{
+			name:     "mapping alone needs no default MSP",
+			property: Property{Owner: "bob"},
+			want:     Property{Owner: "Org2MSP::bob"},
+			changed:  true,
+		},
+		{
+			name:         "qualified IDs are left alone",
+			property:     Property{Owner: "Org3MSP::dave", Owners: []OwnershipShare{{OwnerID: "Org3MSP::dave", Share: 100}}},
+			defaultMSPID: "Org1MSP",
+			want:         Property{Owner: "Org3MSP::dave", Owners: []OwnershipShare{{OwnerID: "Org3MSP::dave", Share: 100}}},
+		},
+		{
+			name:     "bare ID without mapping or default MSP",
+			property: Property{Owner: "erin"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			property := tt.property
+			changed, err := qualifyOwners(&property, tt.defaultMSPID, mapping)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("qualifyOwners = %+v, want an error", property)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("qualifyOwners: %v", err)
+			}
+			if changed != tt.changed {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+			if !reflect.DeepEqual(property, tt.want) {
+				t.Errorf("property = %+v, want %+v", property, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// leaseObjectType keys leases by property so they can be listed per parcel
+const leaseObjectType = "lease"
+
+// Lease statuses
+const (
+	leaseActive     = "ACTIVE"
+	leaseTerminated = "TERMINATED"
+)
+
+// Lease is a tenancy registered by the owner against a parcel or one unit of it.
+// An empty UnitID lets the whole parcel.
+type Lease struct {
+	LeaseID           string    `json:"leaseId"`
+	PropertyID        string    `json:"propertyId"`
+	UnitID            string    `json:"unitId"`
+	TenantID          string    `json:"tenantId"`
+	TenantName        string    `json:"tenantName"`
+	MonthlyRent       float64   `json:"monthlyRent"`
+	Deposit           float64   `json:"deposit"`
+	StartDate         time.Time `json:"startDate"`
+	EndDate           time.Time `json:"endDate"`
+	RenewalTerms      string    `json:"renewalTerms"`
+	Renewals          int       `json:"renewals"`
+	Status            string    `json:"status"` // ACTIVE, TERMINATED
+	RegisteredBy      string    `json:"registeredBy"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+	TerminatedAt      time.Time `json:"terminatedAt"`
+	TerminationReason string    `json:"terminationReason"`
+}
+
+// leaseKey returns the composite ledger key of a lease
+func leaseKey(ctx contractapi.TransactionContextInterface, propertyID string, leaseID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(leaseObjectType, []string{propertyID, leaseID})
+}
+
+// inForce reports whether the lease binds the property at the given time
+func (l *Lease) inForce(at time.Time) bool {
+	return l.Status == leaseActive && !at.Before(l.StartDate) && at.Before(l.EndDate)
+}
+
+// overlaps reports whether two leases let the same space for intersecting terms
+func (l *Lease) overlaps(other *Lease, start time.Time, end time.Time) bool {
+	if other.Status != leaseActive || other.LeaseID == l.LeaseID {
+		return false
+	}
+	sameSpace := l.UnitID == "" || other.UnitID == "" || l.UnitID == other.UnitID
+	return sameSpace && start.Before(other.EndDate) && other.StartDate.Before(end)
+}
+
+// CreateLease registers a tenancy on a verified property. Dates are RFC 3339 and
+// the term may not overlap another active lease of the same unit.
+func (c *PropertyContract) CreateLease(ctx contractapi.TransactionContextInterface, propertyID string, leaseID string, unitID string, tenantID string, tenantName string, monthlyRent float64, deposit float64, startDate string, endDate string, renewalTerms string) error {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	caller, err := requireOwner(ctx, property)
+	if err != nil {
+		return err
+	}
+
+	switch property.Status {
+	case statusPendingVerification, statusRejected, statusRetired:
+		return fmt.Errorf("property %s cannot be leased while it is %s", propertyID, property.Status)
+	}
+	if err := access.ValidateIdentityID(tenantID); err != nil {
+		return err
+	}
+	if property.isOwner(tenantID) {
+		return fmt.Errorf("an owner cannot lease property %s to themselves", propertyID)
+	}
+	if monthlyRent < 0 || deposit < 0 {
+		return fmt.Errorf("rent and deposit cannot be negative")
+	}
+
+	start, err := time.Parse(time.RFC3339, startDate)
+	if err != nil {
+		return fmt.Errorf("invalid start date: %v", err)
+	}
+	end, err := time.Parse(time.RFC3339, endDate)
+	if err != nil {
+		return fmt.Errorf("invalid end date: %v", err)
+	}
+	if !end.After(start) {
+		return fmt.Errorf("end date must be after start date")
+	}
+
+	key, err := leaseKey(ctx, propertyID, leaseID)
+	if err != nil {
+		return err
+	}
+	existingJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read lease: %v", err)
+	}
+	if existingJSON != nil {
+		return fmt.Errorf("lease %s already exists on property %s", leaseID, propertyID)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	lease := &Lease{
+		LeaseID:      leaseID,
+		PropertyID:   propertyID,
+		UnitID:       unitID,
+		TenantID:     tenantID,
+		TenantName:   tenantName,
+		MonthlyRent:  monthlyRent,
+		Deposit:      deposit,
+		StartDate:    start,
+		EndDate:      end,
+		RenewalTerms: renewalTerms,
+		Status:       leaseActive,
+		RegisteredBy: caller.ID,
+		CreatedAt:    timestamp,
+		UpdatedAt:    timestamp,
+	}
+
+	if err := c.checkLeaseOverlap(ctx, lease, start, end); err != nil {
+		return err
+	}
+
+	return putLease(ctx, lease)
+}
+
+// RenewLease extends an active lease to a new end date at a new monthly rent
+func (c *PropertyContract) RenewLease(ctx contractapi.TransactionContextInterface, propertyID string, leaseID string, endDate string, monthlyRent float64) error {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := requireOwner(ctx, property); err != nil {
+		return err
+	}
+
+	lease, err := getLease(ctx, propertyID, leaseID)
+	if err != nil {
+		return err
+	}
+	if lease.Status != leaseActive {
+		return fmt.Errorf("lease %s is %s", leaseID, lease.Status)
+	}
+	if monthlyRent < 0 {
+		return fmt.Errorf("rent cannot be negative")
+	}
+
+	end, err := time.Parse(time.RFC3339, endDate)
+	if err != nil {
+		return fmt.Errorf("invalid end date: %v", err)
+	}
+	if !end.After(lease.EndDate) {
+		return fmt.Errorf("renewed end date must be after the current end date %s", lease.EndDate.Format(time.RFC3339))
+	}
+
+	// Only the extension can collide with other leases
+	if err := c.checkLeaseOverlap(ctx, lease, lease.EndDate, end); err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	lease.EndDate = end
+	lease.MonthlyRent = monthlyRent
+	lease.Renewals++
+	lease.UpdatedAt = timestamp
+
+	return putLease(ctx, lease)
+}
+
+// TerminateLease ends a lease early; the owner or the tenant may terminate it
+func (c *PropertyContract) TerminateLease(ctx contractapi.TransactionContextInterface, propertyID string, leaseID string, reason string) error {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	lease, err := getLease(ctx, propertyID, leaseID)
+	if err != nil {
+		return err
+	}
+
+	caller, err := access.GetCaller(ctx)
+	if err != nil {
+		return err
+	}
+	if !property.isOwner(caller.ID) && caller.ID != lease.TenantID {
+		return fmt.Errorf("access denied: caller %s is neither an owner of property %s nor the tenant of lease %s", caller.ID, propertyID, leaseID)
+	}
+	if lease.Status != leaseActive {
+		return fmt.Errorf("lease %s is already %s", leaseID, lease.Status)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	lease.Status = leaseTerminated
+	lease.TerminatedAt = timestamp
+	lease.TerminationReason = reason
+	lease.UpdatedAt = timestamp
+
+	return putLease(ctx, lease)
+}
+
+// GetLeases returns every lease, current, future, expired or terminated, of a property
+func (c *PropertyContract) GetLeases(ctx contractapi.TransactionContextInterface, propertyID string) ([]*Lease, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(leaseObjectType, []string{propertyID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	leases := []*Lease{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var lease Lease
+		err = json.Unmarshal(queryResponse.Value, &lease)
+		if err != nil {
+			return nil, err
+		}
+		leases = append(leases, &lease)
+	}
+
+	return leases, nil
+}
+
+// GetActiveLeases returns the leases in force now, ordered by unit
+func (c *PropertyContract) GetActiveLeases(ctx contractapi.TransactionContextInterface, propertyID string) ([]*Lease, error) {
+	leases, err := c.GetLeases(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	now := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	active := []*Lease{}
+	for _, lease := range leases {
+		if lease.inForce(now) {
+			active = append(active, lease)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].UnitID < active[j].UnitID
+	})
+
+	return active, nil
+}
+
+// GetActiveLease returns the lease in force now. A property let unit by unit
+// returns its first unit; use GetActiveLeases to list them all.
+func (c *PropertyContract) GetActiveLease(ctx contractapi.TransactionContextInterface, propertyID string) (*Lease, error) {
+	active, err := c.GetActiveLeases(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(active) == 0 {
+		return nil, fmt.Errorf("property %s has no active lease", propertyID)
+	}
+	return active[0], nil
+}
+
+// requireUnleased rejects retiring a parcel whose current or future tenancies
+// would be left attached to it
+func (c *PropertyContract) requireUnleased(ctx contractapi.TransactionContextInterface, propertyID string) error {
+	leases, err := c.GetLeases(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	now := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	for _, lease := range leases {
+		if lease.Status == leaseActive && lease.EndDate.After(now) {
+			return fmt.Errorf("property %s has active lease %s until %s", propertyID, lease.LeaseID, lease.EndDate.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// checkLeaseOverlap rejects a term that overlaps another active lease of the same unit
+func (c *PropertyContract) checkLeaseOverlap(ctx contractapi.TransactionContextInterface, lease *Lease, start time.Time, end time.Time) error {
+	leases, err := c.GetLeases(ctx, lease.PropertyID)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range leases {
+		if lease.overlaps(other, start, end) {
+			return fmt.Errorf("lease %s overlaps lease %s of property %s from %s to %s", lease.LeaseID, other.LeaseID, lease.PropertyID, other.StartDate.Format(time.RFC3339), other.EndDate.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// getLease reads one lease of a property
+func getLease(ctx contractapi.TransactionContextInterface, propertyID string, leaseID string) (*Lease, error) {
+	key, err := leaseKey(ctx, propertyID, leaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	leaseJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lease: %v", err)
+	}
+	if leaseJSON == nil {
+		return nil, fmt.Errorf("lease %s does not exist on property %s", leaseID, propertyID)
+	}
+
+	var lease Lease
+	err = json.Unmarshal(leaseJSON, &lease)
+	if err != nil {
+		return nil, err
+	}
+
+	return &lease, nil
+}
+
+// putLease writes a lease under its composite key
+func putLease(ctx contractapi.TransactionContextInterface, lease *Lease) error {
+	key, err := leaseKey(ctx, lease.PropertyID, lease.LeaseID)
+	if err != nil {
+		return err
+	}
+
+	leaseJSON, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, leaseJSON)
+}
@@ -0,0 +1,62 @@
+package main
+
+// Property lifecycle statuses
+const (
+	statusPendingVerification = "PENDING_VERIFICATION"
+	statusChangesRequested    = "CHANGES_REQUESTED"
+	statusVerified            = "VERIFIED"
+	statusRejected            = "REJECTED"
+	statusAvailable           = "AVAILABLE"
+	statusUnderContract       = "UNDER_CONTRACT"
+	statusSold                = "SOLD"
+	statusWithdrawn           = "WITHDRAWN"
+	statusRetired             = "RETIRED"
+)
+
+// statusTransitions declares every permitted lifecycle move. A property is
+// verified once, listed by its owner, placed under contract when an offer is
+// accepted and sold on transfer; registrations sent back for changes or rejected
+// may be resubmitted and listings may be withdrawn and relisted. Parcels retired by a subdivision or
+// merger are final and live on through their successors.
+var statusTransitions = map[string][]string{
+	statusPendingVerification: {statusVerified, statusChangesRequested, statusRejected},
+	statusChangesRequested:    {statusPendingVerification, statusRejected},
+	statusRejected:            {statusPendingVerification},
+	statusVerified:            {statusAvailable, statusWithdrawn},
+	statusAvailable:           {statusUnderContract, statusWithdrawn},
+	statusUnderContract:       {statusSold, statusAvailable},
+	statusSold:                {statusAvailable, statusWithdrawn},
+	statusWithdrawn:           {statusAvailable},
+	statusRetired:             {},
+}
+
+// dedicatedTransactions names the transaction that must be used to enter a
+// status because it carries bookkeeping beyond the status change itself
+var dedicatedTransactions = map[string]string{
+	statusPendingVerification: "ResubmitProperty",
+	statusChangesRequested:    "RequestChanges",
+	statusRejected:            "RejectProperty",
+	statusVerified:            "VerifyProperty",
+	statusUnderContract:       "AcceptOffer",
+	statusSold:                "TransferProperty",
+	statusRetired:             "SubdivideProperty or MergeProperties",
+}
+
+// isKnownStatus reports whether status is part of the property lifecycle
+func isKnownStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// validateTransition checks that a property may move from one status to another
+func validateTransition(propertyID string, from string, to string) error {
+	if !isKnownStatus(to) {
+		return newContractError(errCodeInvalidStatus, "unknown property status %q", to)
+	}
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return newContractError(errCodeInvalidTransition, "property %s cannot move from %s to %s", propertyID, from, to)
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// areaEpsilon is how far, in area units, the successor parcels may drift from
+// the land they replace
+const areaEpsilon = 0.01
+
+// ParcelSpec describes a parcel created by a subdivision. Location and
+// description default to the parent's.
+type ParcelSpec struct {
+	PropertyID  string      `json:"propertyId"`
+	Area        float64     `json:"area"`
+	Price       float64     `json:"price"`
+	Location    string      `json:"location,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Latitude    float64     `json:"latitude"`
+	Longitude   float64     `json:"longitude"`
+	Boundary    *GeoPolygon `json:"boundary,omitempty"`
+}
+
+// SubdivideProperty splits a verified parcel, e.g. between heirs, into children
+// whose areas sum to the parent's. The children inherit the parent's owners and
+// verification; the parent is retired and links to them, and its documents stay
+// registered against it. Registrar admin only.
+func (c *PropertyContract) SubdivideProperty(ctx contractapi.TransactionContextInterface, parentID string, childrenJSON string) error {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return err
+	}
+
+	parent, err := c.getProperty(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	if !isTransferableStatus(parent.Status) {
+		return fmt.Errorf("property %s cannot be subdivided while it is %s", parentID, parent.Status)
+	}
+	if err := requireNotFrozen(parent); err != nil {
+		return err
+	}
+	// Encumbrances are registered against the parcel and would be orphaned by retiring it
+	if err := c.requireUnencumbered(ctx, parentID); err != nil {
+		return err
+	}
+	if err := c.requireUnleased(ctx, parentID); err != nil {
+		return err
+	}
+
+	var children []ParcelSpec
+	err = json.Unmarshal([]byte(childrenJSON), &children)
+	if err != nil {
+		return fmt.Errorf("invalid children: %v", err)
+	}
+	if len(children) < 2 {
+		return fmt.Errorf("a subdivision needs at least 2 children, got %d", len(children))
+	}
+	if err := checkSubdivisionArea(parent, children); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for i, child := range children {
+		if err := c.validateNewParcel(ctx, child.PropertyID, seen); err != nil {
+			return err
+		}
+		if err := validateCoordinates(child.Latitude, child.Longitude); err != nil {
+			return err
+		}
+
+		if child.Boundary == nil {
+			continue
+		}
+		if err := validateBoundary(child.Boundary, child.Area); err != nil {
+			return fmt.Errorf("child %s: %v", child.PropertyID, err)
+		}
+		// The parent still holds the land until this transaction commits
+		if err := c.checkParcelOverlap(ctx, child.PropertyID, child.Boundary, parentID); err != nil {
+			return err
+		}
+		// Siblings are only written by this transaction, so they are compared here
+		for _, sibling := range children[:i] {
+			if err := checkSiblingOverlap(child, sibling); err != nil {
+				return err
+			}
+		}
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	var childIDs []string
+	for _, spec := range children {
+		child := &Property{
+			PropertyID:   spec.PropertyID,
+			Location:     spec.Location,
+			Area:         spec.Area,
+			Price:        spec.Price,
+			Status:       statusVerified,
+			PropertyType: parent.PropertyType,
+			ZoneCode:     parent.ZoneCode,
+			Description:  spec.Description,
+			Documents:    []string{},
+			VerifiedBy:   parent.VerifiedBy,
+			VerifiedAt:   parent.VerifiedAt,
+			RegisteredAt: timestamp,
+			LastUpdated:  timestamp,
+			Latitude:     spec.Latitude,
+			Longitude:    spec.Longitude,
+			Boundary:     spec.Boundary,
+			ParentIDs:    []string{parentID},
+		}
+		if child.Location == "" {
+			child.Location = parent.Location
+		}
+		if child.Description == "" {
+			child.Description = parent.Description
+		}
+		child.setOwners(parent.ownerShares())
+
+		if err := c.putSuccessor(ctx, child); err != nil {
+			return err
+		}
+		childIDs = append(childIDs, child.PropertyID)
+	}
+
+	return c.retireProperty(ctx, parent, childIDs, timestamp)
+}
+
+// MergeProperties combines adjacent parcels with identical ownership and type,
+// e.g. plots assembled for development, into a new parcel covering their total
+// area. The parents are retired and link to it. The boundary is optional, as
+// for RegisterProperty. Registrar admin only.
+func (c *PropertyContract) MergeProperties(ctx contractapi.TransactionContextInterface, propertyIDsJSON string, newID string, boundaryJSON string) error {
+	caller, err := access.RequireRegistrarAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	var propertyIDs []string
+	err = json.Unmarshal([]byte(propertyIDsJSON), &propertyIDs)
+	if err != nil {
+		return fmt.Errorf("invalid property IDs: %v", err)
+	}
+	if len(propertyIDs) < 2 {
+		return fmt.Errorf("a merger needs at least 2 properties, got %d", len(propertyIDs))
+	}
+
+	if err := c.validateNewParcel(ctx, newID, map[string]bool{}); err != nil {
+		return err
+	}
+
+	var parents []*Property
+	seen := map[string]bool{}
+	for _, propertyID := range propertyIDs {
+		if seen[propertyID] {
+			return fmt.Errorf("property %s is listed more than once", propertyID)
+		}
+		seen[propertyID] = true
+
+		parent, err := c.getProperty(ctx, propertyID)
+		if err != nil {
+			return err
+		}
+		if !isTransferableStatus(parent.Status) {
+			return fmt.Errorf("property %s cannot be merged while it is %s", propertyID, parent.Status)
+		}
+		if err := requireNotFrozen(parent); err != nil {
+			return err
+		}
+		if err := c.requireUnencumbered(ctx, propertyID); err != nil {
+			return err
+		}
+		if err := c.requireUnleased(ctx, propertyID); err != nil {
+			return err
+		}
+		if len(parents) > 0 {
+			if parent.PropertyType != parents[0].PropertyType {
+				return fmt.Errorf("property %s is %s but property %s is %s", propertyID, parent.PropertyType, parents[0].PropertyID, parents[0].PropertyType)
+			}
+			if parent.ZoneCode != parents[0].ZoneCode {
+				return fmt.Errorf("property %s is in zone %s but property %s is in zone %s", propertyID, parent.ZoneCode, parents[0].PropertyID, parents[0].ZoneCode)
+			}
+			if !sameOwnership(parent.ownerShares(), parents[0].ownerShares()) {
+				return fmt.Errorf("properties %s and %s do not have the same owners", propertyID, parents[0].PropertyID)
+			}
+		}
+
+		parents = append(parents, parent)
+	}
+	area, price, latitude, longitude := mergedFootprint(parents)
+
+	var boundary *GeoPolygon
+	if boundaryJSON != "" {
+		boundary, err = parseBoundary(boundaryJSON, area)
+		if err != nil {
+			return err
+		}
+		if err := c.checkParcelOverlap(ctx, newID, boundary, propertyIDs...); err != nil {
+			return err
+		}
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	// Each parent was verified separately, so the registrar admin approving the
+	// merger verifies the result; the parents' verifications stay in the lineage
+	merged := &Property{
+		PropertyID:   newID,
+		Location:     parents[0].Location,
+		Area:         area,
+		Price:        price,
+		Status:       statusVerified,
+		PropertyType: parents[0].PropertyType,
+		ZoneCode:     parents[0].ZoneCode,
+		Description:  parents[0].Description,
+		Documents:    []string{},
+		VerifiedBy:   caller.ID,
+		VerifiedAt:   timestamp,
+		RegisteredAt: timestamp,
+		LastUpdated:  timestamp,
+		Latitude:     latitude,
+		Longitude:    longitude,
+		Boundary:     boundary,
+		ParentIDs:    propertyIDs,
+	}
+	merged.setOwners(parents[0].ownerShares())
+
+	if err := c.putSuccessor(ctx, merged); err != nil {
+		return err
+	}
+
+	for _, parent := range parents {
+		if err := c.retireProperty(ctx, parent, []string{newID}, timestamp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetParentProperties returns the parcels a property was subdivided or merged from
+func (c *PropertyContract) GetParentProperties(ctx contractapi.TransactionContextInterface, propertyID string) ([]*Property, error) {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	return c.getProperties(ctx, property.ParentIDs)
+}
+
+// GetChildProperties returns the parcels that replaced a retired property
+func (c *PropertyContract) GetChildProperties(ctx contractapi.TransactionContextInterface, propertyID string) ([]*Property, error) {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	return c.getProperties(ctx, property.ChildIDs)
+}
+
+// getProperties reads properties by ID in order
+func (c *PropertyContract) getProperties(ctx contractapi.TransactionContextInterface, propertyIDs []string) ([]*Property, error) {
+	properties := []*Property{}
+	for _, propertyID := range propertyIDs {
+		property, err := c.GetProperty(ctx, propertyID)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, property)
+	}
+	return properties, nil
+}
+
+// validateNewParcel checks that a successor parcel ID is unused, on the ledger
+// and within the current request
+func (c *PropertyContract) validateNewParcel(ctx contractapi.TransactionContextInterface, propertyID string, seen map[string]bool) error {
+	if propertyID == "" {
+		return fmt.Errorf("property ID is required")
+	}
+	if seen[propertyID] {
+		return fmt.Errorf("property %s is listed more than once", propertyID)
+	}
+	seen[propertyID] = true
+
+	exists, err := c.PropertyExists(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("property %s already exists", propertyID)
+	}
+	return nil
+}
+
+// checkSubdivisionArea checks that every child of a subdivision has a positive
+// area and that together they cover exactly the parent's
+func checkSubdivisionArea(parent *Property, children []ParcelSpec) error {
+	total := 0.0
+	for _, child := range children {
+		if child.Area <= 0 {
+			return fmt.Errorf("area of child %s must be positive", child.PropertyID)
+		}
+		total += child.Area
+	}
+	if math.Abs(total-parent.Area) > areaEpsilon {
+		return fmt.Errorf("children of property %s cover %.2f but the parent covers %.2f", parent.PropertyID, total, parent.Area)
+	}
+	return nil
+}
+
+// mergedFootprint returns the total area and price of merged parcels and their
+// area-weighted centre, where the merged parcel is located
+func mergedFootprint(parents []*Property) (float64, float64, float64, float64) {
+	area, price, latitude, longitude := 0.0, 0.0, 0.0, 0.0
+	for _, parent := range parents {
+		area += parent.Area
+		price += parent.Price
+		latitude += parent.Latitude * parent.Area
+		longitude += parent.Longitude * parent.Area
+	}
+	return area, price, latitude / area, longitude / area
+}
+
+// checkSiblingOverlap rejects two children of a subdivision whose boundaries overlap
+func checkSiblingOverlap(a ParcelSpec, b ParcelSpec) error {
+	if b.Boundary == nil {
+		return nil
+	}
+
+	aRing, err := a.Boundary.ring()
+	if err != nil {
+		return err
+	}
+	bRing, err := b.Boundary.ring()
+	if err != nil {
+		return err
+	}
+
+	overlap := overlapSquareMetres(aRing, bRing)
+	if overlap > overlapTolerance*math.Min(ringAreaSquareMetres(aRing), ringAreaSquareMetres(bRing)) {
+		return newContractError(errCodeParcelOverlap, "boundary of property %s overlaps property %s by %.0f m²", a.PropertyID, b.PropertyID, overlap)
+	}
+	return nil
+}
+
+// sameOwnership reports whether two ownerships list the same owners with the same shares
+func sameOwnership(a []OwnershipShare, b []OwnershipShare) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	shares := map[string]float64{}
+	for _, owner := range a {
+		shares[owner.OwnerID] = owner.Share
+	}
+	for _, owner := range b {
+		share, ok := shares[owner.OwnerID]
+		if !ok || math.Abs(share-owner.Share) > shareEpsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// putSuccessor writes a parcel created by a subdivision or merger with its indexes
+func (c *PropertyContract) putSuccessor(ctx contractapi.TransactionContextInterface, property *Property) error {
+	if err := putGeoIndex(ctx, property); err != nil {
+		return err
+	}
+	if property.Boundary != nil {
+		if err := putParcelIndex(ctx, property); err != nil {
+			return err
+		}
+	}
+	return c.putProperty(ctx, property)
+}
+
+// retireProperty marks a parcel as replaced by its successors
+func (c *PropertyContract) retireProperty(ctx contractapi.TransactionContextInterface, property *Property, childIDs []string, timestamp time.Time) error {
+	property.Status = statusRetired
+	property.ChildIDs = childIDs
+	property.SaleConsents = nil
+	property.LastUpdated = timestamp
+
+	return c.putProperty(ctx, property)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheckSubdivisionArea(t *testing.T) {
+	parent := &Property{PropertyID: "PROP_001", Area: 2400}
+
+	tests := []struct {
+		name     string
+		children []ParcelSpec
+		wantErr  bool
+	}{
+		{"halves", []ParcelSpec{{PropertyID: "A", Area: 1200}, {PropertyID: "B", Area: 1200}}, false},
+		{"uneven thirds", []ParcelSpec{{PropertyID: "A", Area: 1000}, {PropertyID: "B", Area: 800}, {PropertyID: "C", Area: 600}}, false},
+		{"rounding within tolerance", []ParcelSpec{{PropertyID: "A", Area: 800.003}, {PropertyID: "B", Area: 799.999}, {PropertyID: "C", Area: 800}}, false},
+		{"land lost", []ParcelSpec{{PropertyID: "A", Area: 1200}, {PropertyID: "B", Area: 1100}}, true},
+		{"land gained", []ParcelSpec{{PropertyID: "A", Area: 1200}, {PropertyID: "B", Area: 1300}}, true},
+		{"zero-area child", []ParcelSpec{{PropertyID: "A", Area: 2400}, {PropertyID: "B", Area: 0}}, true},
+		{"negative child balancing the total", []ParcelSpec{{PropertyID: "A", Area: 2500}, {PropertyID: "B", Area: -100}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSubdivisionArea(parent, tt.children)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkSubdivisionArea = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMergedFootprint(t *testing.T) {
+	tests := []struct {
+		name          string
+		parents       []*Property
+		area, price   float64
+		latitude, lon float64
+	}{
+		{
+			name: "equal plots meet in the middle",
+			parents: []*Property{
+				{Area: 1200, Price: 100, Latitude: 12.0, Longitude: 77.0},
+				{Area: 1200, Price: 150, Latitude: 12.2, Longitude: 77.2},
+			},
+			area: 2400, price: 250, latitude: 12.1, lon: 77.1,
+		},
+		{
+			name: "larger plot pulls the centre",
+			parents: []*Property{
+				{Area: 3000, Price: 300, Latitude: 12.0, Longitude: 77.0},
+				{Area: 1000, Price: 100, Latitude: 12.4, Longitude: 77.4},
+			},
+			area: 4000, price: 400, latitude: 12.1, lon: 77.1,
+		},
+		{
+			name: "three plots",
+			parents: []*Property{
+				{Area: 500, Price: 50, Latitude: 10, Longitude: 70},
+				{Area: 500, Price: 50, Latitude: 11, Longitude: 71},
+				{Area: 1000, Price: 100, Latitude: 12, Longitude: 72},
+			},
+			area: 2000, price: 200, latitude: 11.25, lon: 71.25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			area, price, latitude, longitude := mergedFootprint(tt.parents)
+			if area != tt.area || price != tt.price {
+				t.Errorf("area, price = %v, %v, want %v, %v", area, price, tt.area, tt.price)
+			}
+			if math.Abs(latitude-tt.latitude) > 1e-9 || math.Abs(longitude-tt.lon) > 1e-9 {
+				t.Errorf("centre = %v, %v, want %v, %v", latitude, longitude, tt.latitude, tt.lon)
+			}
+		})
+	}
+}
+
+func TestSubdivideThenMergeConservesArea(t *testing.T) {
+	parent := &Property{PropertyID: "PROP_001", Area: 2400, Latitude: 12.97, Longitude: 77.59}
+	children := []ParcelSpec{
+		{PropertyID: "A", Area: 1000, Latitude: 12.97, Longitude: 77.59},
+		{PropertyID: "B", Area: 900, Latitude: 12.97, Longitude: 77.59},
+		{PropertyID: "C", Area: 500, Latitude: 12.97, Longitude: 77.59},
+	}
+	if err := checkSubdivisionArea(parent, children); err != nil {
+		t.Fatalf("checkSubdivisionArea: %v", err)
+	}
+
+	var parents []*Property
+	for _, child := range children {
+		parents = append(parents, &Property{Area: child.Area, Latitude: child.Latitude, Longitude: child.Longitude})
+	}
+	if area, _, _, _ := mergedFootprint(parents); math.Abs(area-parent.Area) > areaEpsilon {
+		t.Fatalf("merging the children covers %v, want the parent's %v", area, parent.Area)
+	}
+}
+
+func TestSameOwnership(t *testing.T) {
+	alice := OwnershipShare{OwnerID: "Org1MSP::alice", Share: 60}
+	bob := OwnershipShare{OwnerID: "Org1MSP::bob", Share: 40}
+
+	tests := []struct {
+		name string
+		a, b []OwnershipShare
+		want bool
+	}{
+		{"same owners in another order", []OwnershipShare{alice, bob}, []OwnershipShare{bob, alice}, true},
+		{"different shares", []OwnershipShare{alice, bob}, []OwnershipShare{{OwnerID: alice.OwnerID, Share: 50}, {OwnerID: bob.OwnerID, Share: 50}}, false},
+		{"extra owner", []OwnershipShare{alice, bob}, []OwnershipShare{alice, bob, {OwnerID: "Org2MSP::carol", Share: 0}}, false},
+		{"different owner", []OwnershipShare{alice, bob}, []OwnershipShare{alice, {OwnerID: "Org2MSP::carol", Share: 40}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameOwnership(tt.a, tt.b); got != tt.want {
+				t.Fatalf("sameOwnership = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSiblingOverlap(t *testing.T) {
+	const side = 0.001
+	west := ParcelSpec{PropertyID: "A", Boundary: squareBoundary(12.97, 77.59, side)}
+
+	tests := []struct {
+		name    string
+		sibling ParcelSpec
+		wantErr bool
+	}{
+		{"adjacent", ParcelSpec{PropertyID: "B", Boundary: squareBoundary(12.97, 77.59+side, side)}, false},
+		{"no boundary", ParcelSpec{PropertyID: "B"}, false},
+		{"overlapping", ParcelSpec{PropertyID: "B", Boundary: squareBoundary(12.97, 77.59+side/2, side)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSiblingOverlap(west, tt.sibling)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkSiblingOverlap = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// shareEpsilon absorbs floating point drift when adding up percentage shares
+const shareEpsilon = 1e-6
+
+// OwnershipShare is one owner's percentage of a property
+type OwnershipShare struct {
+	OwnerID   string  `json:"ownerId"`
+	OwnerName string  `json:"ownerName"`
+	Share     float64 `json:"share"`
+}
+
+// SaleConsent records a co-owner's consent, signed with their own identity, to sell to a buyer
+type SaleConsent struct {
+	OwnerID   string    `json:"ownerId"`
+	BuyerID   string    `json:"buyerId"`
+	TxID      string    `json:"txId"`
+	GrantedAt time.Time `json:"grantedAt"`
+}
+
+// ownerShares returns the property's owners, treating properties registered
+// before co-ownership as wholly owned by Owner
+func (p *Property) ownerShares() []OwnershipShare {
+	if len(p.Owners) > 0 {
+		return p.Owners
+	}
+	return []OwnershipShare{{OwnerID: p.Owner, OwnerName: p.OwnerName, Share: 100}}
+}
+
+// isOwner reports whether the user, identified by MSP-qualified ID, holds any
+// share of the property
+func (p *Property) isOwner(userID string) bool {
+	for _, owner := range p.ownerShares() {
+		if owner.OwnerID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// setOwners replaces the ownership and points Owner at the largest shareholder
+func (p *Property) setOwners(owners []OwnershipShare) {
+	p.Owners = owners
+	primary := owners[0]
+	for _, owner := range owners[1:] {
+		if owner.Share > primary.Share+shareEpsilon {
+			primary = owner
+		}
+	}
+	p.Owner = primary.OwnerID
+	p.OwnerName = primary.OwnerName
+}
+
+// hasSaleConsent reports whether every co-owner has consented to selling to the
+// buyer. A sole owner consents by accepting the buyer's offer.
+func (p *Property) hasSaleConsent(buyerID string) bool {
+	owners := p.ownerShares()
+	if len(owners) == 1 {
+		return true
+	}
+
+	for _, owner := range owners {
+		consented := false
+		for _, consent := range p.SaleConsents {
+			if consent.OwnerID == owner.OwnerID && consent.BuyerID == buyerID {
+				consented = true
+				break
+			}
+		}
+		if !consented {
+			return false
+		}
+	}
+	return true
+}
+
+// ownerSelector matches properties the user owns outright or holds a share of
+func ownerSelector(owner string) map[string]interface{} {
+	return map[string]interface{}{
+		"$or": []interface{}{
+			map[string]interface{}{"owner": owner},
+			map[string]interface{}{"owners": map[string]interface{}{
+				"$elemMatch": map[string]interface{}{"ownerId": owner},
+			}},
+		},
+	}
+}
+
+// validateOwnerShares checks that shares are positive, owners unique and the total is 100%
+func validateOwnerShares(owners []OwnershipShare) error {
+	if len(owners) == 0 {
+		return fmt.Errorf("at least one owner is required")
+	}
+
+	total := 0.0
+	seen := map[string]bool{}
+	for _, owner := range owners {
+		if err := access.ValidateIdentityID(owner.OwnerID); err != nil {
+			return err
+		}
+		if seen[owner.OwnerID] {
+			return fmt.Errorf("owner %s is listed more than once", owner.OwnerID)
+		}
+		seen[owner.OwnerID] = true
+		if owner.Share <= 0 {
+			return fmt.Errorf("share of owner %s must be positive", owner.OwnerID)
+		}
+		total += owner.Share
+	}
+
+	if math.Abs(total-100) > shareEpsilon {
+		return fmt.Errorf("ownership shares must sum to 100%%, got %.4f%%", total)
+	}
+	return nil
+}
+
+// moveShare returns the owners after moving a share from one owner to the
+// recipient, dropping owners left with nothing and adding the recipient if new,
+// along with the share the sender held before. It does not check that the
+// sender holds enough.
+func moveShare(owners []OwnershipShare, fromID string, to OwnershipShare) ([]OwnershipShare, float64) {
+	var moved []OwnershipShare
+	held := 0.0
+	recipientIsOwner := false
+	for _, owner := range owners {
+		if owner.OwnerID == fromID {
+			held = owner.Share
+			owner.Share -= to.Share
+		}
+		if owner.OwnerID == to.OwnerID {
+			owner.Share += to.Share
+			recipientIsOwner = true
+		}
+		if owner.Share > shareEpsilon {
+			moved = append(moved, owner)
+		}
+	}
+	if !recipientIsOwner {
+		moved = append(moved, to)
+	}
+	return moved, held
+}
+
+// isTransferableStatus reports whether title may change hands outside a sale
+func isTransferableStatus(status string) bool {
+	return status == statusVerified || status == statusAvailable || status == statusWithdrawn || status == statusSold
+}
+
+// RegisterCoOwnership records the joint owners of a property and their percentage
+// shares, e.g. a title held by spouses. Only a registrar admin may set it.
+func (c *PropertyContract) RegisterCoOwnership(ctx contractapi.TransactionContextInterface, propertyID string, ownersJSON string) error {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return err
+	}
+
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	if property.Status == statusUnderContract || property.Status == statusRetired {
+		return fmt.Errorf("property %s is %s", propertyID, property.Status)
+	}
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+
+	var owners []OwnershipShare
+	err = json.Unmarshal([]byte(ownersJSON), &owners)
+	if err != nil {
+		return fmt.Errorf("invalid owners: %v", err)
+	}
+	if err := validateOwnerShares(owners); err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	property.setOwners(owners)
+	property.SaleConsents = nil
+	property.LastUpdated = timestamp
+
+	return c.putProperty(ctx, property)
+}
+
+// TransferShare sells or gifts part of the caller's share of a property to
+// another user, who becomes a co-owner, and records the transaction. It cannot
+// leave the recipient as sole owner; that is a whole-title transfer. Duty and
+// fees are assessed on the share transferred; feePaymentRef is required when
+// any are owed.
+func (c *PropertyContract) TransferShare(ctx contractapi.TransactionContextInterface, propertyID string, toOwnerID string, toOwnerName string, share float64, transactionID string, transferType string, consideration float64, feePaymentRef string) error {
+	caller, err := access.GetCaller(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !isVoluntaryTransfer(transferType) {
+		return fmt.Errorf("shares can only be transferred by %s or %s", transferSale, transferGift)
+	}
+	terms := &transferTerms{TransferType: transferType, Consideration: consideration, FeePaymentRef: feePaymentRef}
+	if err := validateTransferTerms(terms); err != nil {
+		return err
+	}
+
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	if !isTransferableStatus(property.Status) {
+		return fmt.Errorf("shares of property %s cannot be transferred while it is %s", propertyID, property.Status)
+	}
+	if share <= 0 {
+		return fmt.Errorf("share must be positive")
+	}
+	if toOwnerID == caller.ID {
+		return fmt.Errorf("cannot transfer a share to yourself")
+	}
+	if err := access.ValidateIdentityID(toOwnerID); err != nil {
+		return err
+	}
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+	if err := c.requireUnencumbered(ctx, propertyID); err != nil {
+		return err
+	}
+
+	owners, held := moveShare(property.ownerShares(), caller.ID, OwnershipShare{OwnerID: toOwnerID, OwnerName: toOwnerName, Share: share})
+	if held == 0 {
+		return fmt.Errorf("access denied: caller %s holds no share of property %s", caller.ID, propertyID)
+	}
+	if share > held+shareEpsilon {
+		return fmt.Errorf("caller %s holds %.4f%% of property %s and cannot transfer %.4f%%", caller.ID, held, propertyID, share)
+	}
+	// Handing over the whole title is a sale or gift of the property, which
+	// TransferProperty records through the lifecycle (UNDER_CONTRACT to SOLD)
+	if len(owners) == 1 {
+		return fmt.Errorf("transferring %.4f%% of property %s leaves %s its sole owner; transfer the whole title with TransferProperty", share, propertyID, toOwnerID)
+	}
+
+	fees, err := c.transferFees(ctx, property, terms.Consideration, share)
+	if err != nil {
+		return err
+	}
+	if fees.TotalFees > 0 && terms.FeePaymentRef == "" {
+		return fmt.Errorf("transfer of %.4f%% of property %s owes %.2f in stamp duty and registration fees; a fee payment reference is required", share, propertyID, fees.TotalFees)
+	}
+
+	transaction, err := newTransaction(ctx, transactionID, propertyID)
+	if err != nil {
+		return err
+	}
+	transaction.TransferType = transferType
+	transaction.FromOwner = caller.ID
+	transaction.ToOwner = toOwnerID
+	transaction.Amount = consideration
+	transaction.Share = share
+	transaction.StampDuty = fees.StampDuty
+	transaction.RegistrationFee = fees.RegistrationFee
+	transaction.FeeScheduleVersion = fees.FeeScheduleVersion
+	transaction.FeePaymentRef = terms.FeePaymentRef
+	transaction.GuidelineValue = fees.GuidelineValue
+	transaction.BelowGuideline = fees.BelowGuideline && transferType == transferSale
+
+	property.setOwners(owners)
+	property.SaleConsents = nil
+	property.LastUpdated = transaction.Timestamp
+
+	if err := c.putProperty(ctx, property); err != nil {
+		return err
+	}
+
+	return putTransaction(ctx, transaction)
+}
+
+// GrantSaleConsent records the calling co-owner's consent to sell the property to a buyer
+func (c *PropertyContract) GrantSaleConsent(ctx contractapi.TransactionContextInterface, propertyID string, buyerID string) error {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	caller, err := requireOwner(ctx, property)
+	if err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	consents := []SaleConsent{}
+	for _, consent := range property.SaleConsents {
+		if consent.OwnerID != caller.ID {
+			consents = append(consents, consent)
+		}
+	}
+	consents = append(consents, SaleConsent{
+		OwnerID:   caller.ID,
+		BuyerID:   buyerID,
+		TxID:      ctx.GetStub().GetTxID(),
+		GrantedAt: timestamp,
+	})
+
+	property.SaleConsents = consents
+	property.LastUpdated = timestamp
+
+	return c.putProperty(ctx, property)
+}
+
+// RevokeSaleConsent withdraws the calling co-owner's consent to sell the property
+func (c *PropertyContract) RevokeSaleConsent(ctx contractapi.TransactionContextInterface, propertyID string) error {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	caller, err := requireOwner(ctx, property)
+	if err != nil {
+		return err
+	}
+
+	var consents []SaleConsent
+	for _, consent := range property.SaleConsents {
+		if consent.OwnerID != caller.ID {
+			consents = append(consents, consent)
+		}
+	}
+
+	property.SaleConsents = consents
+
+	return c.putProperty(ctx, property)
+}
+
+// HasSaleConsent reports whether every co-owner has consented to selling the property to a buyer
+func (c *PropertyContract) HasSaleConsent(ctx contractapi.TransactionContextInterface, propertyID string, buyerID string) (bool, error) {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return false, err
+	}
+	return property.hasSaleConsent(buyerID), nil
+}
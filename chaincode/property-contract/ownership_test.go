@@ -0,0 +1,169 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateOwnerShares(t *testing.T) {
+	tests := []struct {
+		name    string
+		owners  []OwnershipShare
+		wantErr bool
+	}{
+		{"sole owner", []OwnershipShare{{OwnerID: "Org1MSP::alice", Share: 100}}, false},
+		{"equal thirds", []OwnershipShare{
+			{OwnerID: "Org1MSP::alice", Share: 100.0 / 3},
+			{OwnerID: "Org1MSP::bob", Share: 100.0 / 3},
+			{OwnerID: "Org2MSP::carol", Share: 100.0 / 3},
+		}, false},
+		{"no owners", nil, true},
+		{"short of 100%", []OwnershipShare{
+			{OwnerID: "Org1MSP::alice", Share: 60},
+			{OwnerID: "Org1MSP::bob", Share: 39},
+		}, true},
+		{"over 100%", []OwnershipShare{
+			{OwnerID: "Org1MSP::alice", Share: 60},
+			{OwnerID: "Org1MSP::bob", Share: 41},
+		}, true},
+		{"zero share", []OwnershipShare{
+			{OwnerID: "Org1MSP::alice", Share: 100},
+			{OwnerID: "Org1MSP::bob", Share: 0},
+		}, true},
+		{"negative share", []OwnershipShare{
+			{OwnerID: "Org1MSP::alice", Share: 110},
+			{OwnerID: "Org1MSP::bob", Share: -10},
+		}, true},
+		{"owner listed twice", []OwnershipShare{
+			{OwnerID: "Org1MSP::alice", Share: 50},
+			{OwnerID: "Org1MSP::alice", Share: 50},
+		}, true},
+		{"bare owner ID", []OwnershipShare{{OwnerID: "alice", Share: 100}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOwnerShares(tt.owners)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateOwnerShares = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMoveShare(t *testing.T) {
+	alice := OwnershipShare{OwnerID: "Org1MSP::alice", OwnerName: "Alice", Share: 60}
+	bob := OwnershipShare{OwnerID: "Org1MSP::bob", OwnerName: "Bob", Share: 40}
+
+	tests := []struct {
+		name     string
+		owners   []OwnershipShare
+		to       OwnershipShare
+		want     []OwnershipShare
+		wantHeld float64
+	}{
+		{
+			name:   "part of a share to a new owner",
+			owners: []OwnershipShare{alice, bob},
+			to:     OwnershipShare{OwnerID: "Org2MSP::carol", OwnerName: "Carol", Share: 20},
+			want: []OwnershipShare{
+				{OwnerID: "Org1MSP::alice", OwnerName: "Alice", Share: 40},
+				bob,
+				{OwnerID: "Org2MSP::carol", OwnerName: "Carol", Share: 20},
+			},
+			wantHeld: 60,
+		},
+		{
+			name:   "part of a share to a co-owner",
+			owners: []OwnershipShare{alice, bob},
+			to:     OwnershipShare{OwnerID: "Org1MSP::bob", OwnerName: "Bob", Share: 10},
+			want: []OwnershipShare{
+				{OwnerID: "Org1MSP::alice", OwnerName: "Alice", Share: 50},
+				{OwnerID: "Org1MSP::bob", OwnerName: "Bob", Share: 50},
+			},
+			wantHeld: 60,
+		},
+		{
+			name:     "whole share to a co-owner drops the sender",
+			owners:   []OwnershipShare{alice, bob},
+			to:       OwnershipShare{OwnerID: "Org1MSP::bob", OwnerName: "Bob", Share: 60},
+			want:     []OwnershipShare{{OwnerID: "Org1MSP::bob", OwnerName: "Bob", Share: 100}},
+			wantHeld: 60,
+		},
+		{
+			name:   "whole share to a new owner replaces the sender",
+			owners: []OwnershipShare{alice, bob},
+			to:     OwnershipShare{OwnerID: "Org2MSP::carol", OwnerName: "Carol", Share: 60},
+			want: []OwnershipShare{
+				bob,
+				{OwnerID: "Org2MSP::carol", OwnerName: "Carol", Share: 60},
+			},
+			wantHeld: 60,
+		},
+		{
+			name:     "sender holds nothing",
+			owners:   []OwnershipShare{bob},
+			to:       OwnershipShare{OwnerID: "Org2MSP::carol", Share: 10},
+			want:     []OwnershipShare{bob, {OwnerID: "Org2MSP::carol", Share: 10}},
+			wantHeld: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, held := moveShare(tt.owners, "Org1MSP::alice", tt.to)
+			if held != tt.wantHeld {
+				t.Errorf("held = %v, want %v", held, tt.wantHeld)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("owners = %+v, want %+v", got, tt.want)
+			}
+			if tt.wantHeld > 0 {
+				if err := validateOwnerShares(got); err != nil {
+					t.Errorf("moved shares are invalid: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestMoveShareLeavesInputUnchanged(t *testing.T) {
+	owners := []OwnershipShare{
+		{OwnerID: "Org1MSP::alice", Share: 60},
+		{OwnerID: "Org1MSP::bob", Share: 40},
+	}
+	moveShare(owners, "Org1MSP::alice", OwnershipShare{OwnerID: "Org1MSP::bob", Share: 30})
+	if owners[0].Share != 60 || owners[1].Share != 40 {
+		t.Fatalf("moveShare modified its input: %+v", owners)
+	}
+}
+
+func TestSetOwnersPointsOwnerAtLargestShare(t *testing.T) {
+	tests := []struct {
+		name   string
+		owners []OwnershipShare
+		want   string
+	}{
+		{"largest last", []OwnershipShare{
+			{OwnerID: "Org1MSP::alice", OwnerName: "Alice", Share: 30},
+			{OwnerID: "Org1MSP::bob", OwnerName: "Bob", Share: 70},
+		}, "Org1MSP::bob"},
+		{"tie keeps the first listed", []OwnershipShare{
+			{OwnerID: "Org1MSP::alice", OwnerName: "Alice", Share: 50},
+			{OwnerID: "Org1MSP::bob", OwnerName: "Bob", Share: 50},
+		}, "Org1MSP::alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var property Property
+			property.setOwners(tt.owners)
+			if property.Owner != tt.want {
+				t.Fatalf("Owner = %s, want %s", property.Owner, tt.want)
+			}
+			if !property.isOwner("Org1MSP::alice") || !property.isOwner("Org1MSP::bob") || property.isOwner("Org2MSP::carol") {
+				t.Fatalf("isOwner does not match owners %+v", property.Owners)
+			}
+		})
+	}
+}
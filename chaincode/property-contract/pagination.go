@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+
+	"couchquery"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// PaginatedPropertyResult is one page of properties plus the bookmark for the next page
+type PaginatedPropertyResult struct {
+	Records      []*Property `json:"records"`
+	Bookmark     string      `json:"bookmark"`
+	FetchedCount int32       `json:"fetchedCount"`
+}
+
+// GetAllPropertiesWithPagination retrieves one page of all properties
+func (c *PropertyContract) GetAllPropertiesWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedPropertyResult, error) {
+	if err := couchquery.ValidatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(propertyObjectType, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return constructPropertyPage(ctx, resultsIterator, metadata)
+}
+
+// GetPropertiesByStatusWithPagination retrieves one page of properties with a status
+func (c *PropertyContract) GetPropertiesByStatusWithPagination(ctx contractapi.TransactionContextInterface, status string, pageSize int32, bookmark string) (*PaginatedPropertyResult, error) {
+	queryString, err := couchquery.Build(propertySelector(map[string]interface{}{"status": status}))
+	if err != nil {
+		return nil, err
+	}
+	return c.queryPropertiesWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// GetPropertiesByTypeWithPagination retrieves one page of properties of a type
+func (c *PropertyContract) GetPropertiesByTypeWithPagination(ctx contractapi.TransactionContextInterface, propertyType string, pageSize int32, bookmark string) (*PaginatedPropertyResult, error) {
+	queryString, err := couchquery.Build(propertySelector(map[string]interface{}{"propertyType": propertyType}))
+	if err != nil {
+		return nil, err
+	}
+	return c.queryPropertiesWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// GetPropertiesByOwnerWithPagination retrieves one page of an owner's properties
+func (c *PropertyContract) GetPropertiesByOwnerWithPagination(ctx contractapi.TransactionContextInterface, owner string, pageSize int32, bookmark string) (*PaginatedPropertyResult, error) {
+	queryString, err := couchquery.Build(propertySelector(ownerSelector(owner)))
+	if err != nil {
+		return nil, err
+	}
+	return c.queryPropertiesWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// queryPropertiesWithPagination runs a rich query and returns one page of properties
+func (c *PropertyContract) queryPropertiesWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int32, bookmark string) (*PaginatedPropertyResult, error) {
+	if err := couchquery.ValidatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return constructPropertyPage(ctx, resultsIterator, metadata)
+}
+
+// constructPropertyPage reads a page of property documents, skipping other entity
+// types that a rich query may have matched
+func constructPropertyPage(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface, metadata *peer.QueryResponseMetadata) (*PaginatedPropertyResult, error) {
+	properties := []*Property{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !hasObjectType(ctx, queryResponse.Key, propertyObjectType) {
+			continue
+		}
+
+		var property Property
+		err = json.Unmarshal(queryResponse.Value, &property)
+		if err != nil {
+			return nil, err
+		}
+		if err := withCompactedViews(ctx, &property); err != nil {
+			return nil, err
+		}
+		properties = append(properties, &property)
+	}
+
+	return &PaginatedPropertyResult{
+		Records:      properties,
+		Bookmark:     metadata.Bookmark,
+		FetchedCount: metadata.FetchedRecordsCount,
+	}, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"couchquery"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// priceChangeObjectType keys price changes by property and time so a partial
+// key scan returns them in order
+const priceChangeObjectType = "pricechange"
+
+// sortableTimestampLayout is a fixed-width UTC layout, valid RFC 3339, that
+// sorts lexically in time order
+const sortableTimestampLayout = "2006-01-02T15:04:05.000000000Z"
+
+// sortableTimestamp formats a time in sortableTimestampLayout
+func sortableTimestamp(t time.Time) string {
+	return t.UTC().Format(sortableTimestampLayout)
+}
+
+// PriceChange records one change to a property's asking price
+type PriceChange struct {
+	PropertyID string    `json:"propertyId"`
+	OldPrice   float64   `json:"oldPrice"`
+	NewPrice   float64   `json:"newPrice"`
+	ChangedBy  string    `json:"changedBy"`
+	TxID       string    `json:"txId"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// PriceStatistic summarizes the price per area unit of verified properties of
+// one type in one location
+type PriceStatistic struct {
+	PropertyType       string  `json:"propertyType"`
+	Location           string  `json:"location"`
+	Count              int     `json:"count"`
+	MedianPricePerArea float64 `json:"medianPricePerArea"`
+	MinPricePerArea    float64 `json:"minPricePerArea"`
+	MaxPricePerArea    float64 `json:"maxPricePerArea"`
+}
+
+// verifiedInventoryStatuses are the statuses of properties with verified, live title
+var verifiedInventoryStatuses = []string{statusVerified, statusAvailable, statusUnderContract, statusSold, statusWithdrawn}
+
+// putPriceChange records a price change made by the current transaction
+func putPriceChange(ctx contractapi.TransactionContextInterface, propertyID string, oldPrice float64, newPrice float64, changedBy string, timestamp time.Time) error {
+	txID := ctx.GetStub().GetTxID()
+	key, err := ctx.GetStub().CreateCompositeKey(priceChangeObjectType, []string{propertyID, sortableTimestamp(timestamp), txID})
+	if err != nil {
+		return err
+	}
+
+	change := &PriceChange{
+		PropertyID: propertyID,
+		OldPrice:   oldPrice,
+		NewPrice:   newPrice,
+		ChangedBy:  changedBy,
+		TxID:       txID,
+		Timestamp:  timestamp,
+	}
+
+	changeJSON, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, changeJSON)
+}
+
+// GetPriceHistory returns the price changes of a property, oldest first
+func (c *PropertyContract) GetPriceHistory(ctx contractapi.TransactionContextInterface, propertyID string) ([]*PriceChange, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(priceChangeObjectType, []string{propertyID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	changes := []*PriceChange{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var change PriceChange
+		err = json.Unmarshal(queryResponse.Value, &change)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, &change)
+	}
+
+	return changes, nil
+}
+
+// GetPriceStatistics returns the median, minimum and maximum price per area
+// unit of the verified inventory grouped by property type and location.
+// Locations are compared case-insensitively; empty filters match everything.
+func (c *PropertyContract) GetPriceStatistics(ctx contractapi.TransactionContextInterface, propertyType string, location string) ([]*PriceStatistic, error) {
+	selector := propertySelector(map[string]interface{}{
+		"status": map[string]interface{}{"$in": verifiedInventoryStatuses},
+	})
+	if propertyType != "" {
+		selector["propertyType"] = propertyType
+	}
+
+	queryString, err := couchquery.Build(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	properties, err := c.queryProperties(ctx, queryString)
+	if err != nil {
+		return nil, err
+	}
+
+	location = normalizeLocation(location)
+	groups := map[[2]string][]float64{}
+	for _, property := range properties {
+		if property.Area <= 0 {
+			continue
+		}
+		propertyLocation := normalizeLocation(property.Location)
+		if location != "" && propertyLocation != location {
+			continue
+		}
+		group := [2]string{property.PropertyType, propertyLocation}
+		groups[group] = append(groups[group], property.Price/property.Area)
+	}
+
+	statistics := []*PriceStatistic{}
+	for group, prices := range groups {
+		sort.Float64s(prices)
+		statistics = append(statistics, &PriceStatistic{
+			PropertyType:       group[0],
+			Location:           group[1],
+			Count:              len(prices),
+			MedianPricePerArea: median(prices),
+			MinPricePerArea:    prices[0],
+			MaxPricePerArea:    prices[len(prices)-1],
+		})
+	}
+	sort.Slice(statistics, func(i, j int) bool {
+		if statistics[i].PropertyType != statistics[j].PropertyType {
+			return statistics[i].PropertyType < statistics[j].PropertyType
+		}
+		return statistics[i].Location < statistics[j].Location
+	})
+
+	return statistics, nil
+}
+
+// normalizeLocation folds case and surrounding space so free-text locations group together
+func normalizeLocation(location string) string {
+	return strings.ToLower(strings.TrimSpace(location))
+}
+
+// median returns the median of sorted values
+func median(sorted []float64) float64 {
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[middle]
+	}
+	return (sorted[middle-1] + sorted[middle]) / 2
+}
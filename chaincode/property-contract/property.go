@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"time"
 
+	"access"
+	"couchquery"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 )
 
 type PropertyContract struct {
@@ -14,23 +17,36 @@ type PropertyContract struct {
 
 // Property represents a land property with enhanced fields
 type Property struct {
+	DocType          string    `json:"docType"` // always "property"; constrains rich queries to properties
 	PropertyID       string    `json:"propertyId"`
 	Owner            string    `json:"owner"`
 	OwnerName        string    `json:"ownerName"`
 	Location         string    `json:"location"`
 	Area             float64   `json:"area"`
 	Price            float64   `json:"price"`
-	Status           string    `json:"status"` // AVAILABLE, PENDING_VERIFICATION, VERIFIED, UNDER_CONTRACT, SOLD
-	PropertyType     string    `json:"propertyType"` // RESIDENTIAL, COMMERCIAL, AGRICULTURAL
+	Status           string    `json:"status"` // see statusTransitions in lifecycle.go
+	PropertyType     string    `json:"propertyType"` // RESIDENTIAL, COMMERCIAL, AGRICULTURAL, INDUSTRIAL
 	Description      string    `json:"description"`
 	Documents        []string  `json:"documents"`
 	VerifiedBy       string    `json:"verifiedBy"`
 	VerifiedAt       time.Time `json:"verifiedAt"`
 	RegisteredAt     time.Time `json:"registeredAt"`
+	RegisteredAtKey  string    `json:"registeredAtKey"` // RegisteredAt in sortableTimestampLayout, for exact sorting
 	LastUpdated      time.Time `json:"lastUpdated"`
 	Views            int       `json:"views"`
 	Latitude         float64   `json:"latitude"`
 	Longitude        float64   `json:"longitude"`
+	Geohash          string    `json:"geohash"`
+	Boundary         *GeoPolygon `json:"boundary,omitempty" metadata:",optional"`
+	Owners           []OwnershipShare `json:"owners,omitempty" metadata:",optional"`
+	SaleConsents     []SaleConsent    `json:"saleConsents,omitempty" metadata:",optional"`
+	ParentIDs        []string         `json:"parentIds,omitempty" metadata:",optional"`
+	ChildIDs         []string         `json:"childIds,omitempty" metadata:",optional"`
+	Reviews          []ReviewEntry    `json:"reviews,omitempty" metadata:",optional"`
+	Approvals        []Approval       `json:"approvals,omitempty" metadata:",optional"`
+	RejectionReason  string           `json:"rejectionReason,omitempty" metadata:",optional"`
+	FrozenBy         []string         `json:"frozenBy,omitempty" metadata:",optional"` // disputes freezing the property
+	ZoneCode         string           `json:"zoneCode,omitempty" metadata:",optional"`
 }
 
 // User represents a system user
@@ -48,19 +64,46 @@ type User struct {
 
 // Transaction represents a property transaction
 type Transaction struct {
-	TransactionID string    `json:"transactionId"`
-	PropertyID    string    `json:"propertyId"`
-	FromOwner     string    `json:"fromOwner"`
-	ToOwner       string    `json:"toOwner"`
-	Amount        float64   `json:"amount"`
-	Status        string    `json:"status"` // PENDING, COMPLETED, CANCELLED
-	EscrowID      string    `json:"escrowId"`
-	Timestamp     time.Time `json:"timestamp"`
+	DocType            string            `json:"docType"` // always "txn"; constrains rich queries to transactions
+	TransactionID      string            `json:"transactionId"`
+	PropertyID         string            `json:"propertyId"`
+	TransferType       string            `json:"transferType,omitempty" metadata:",optional"` // SALE, GIFT, SUCCESSION, COURT_ORDER, GOVERNMENT_ACQUISITION
+	FromOwner          string            `json:"fromOwner"`
+	ToOwner            string            `json:"toOwner"`
+	Amount             float64           `json:"amount"`
+	Status             string            `json:"status"` // PENDING, COMPLETED, CANCELLED
+	EscrowID           string            `json:"escrowId"`
+	Share              float64           `json:"share"` // percentage of the property transferred
+	FromOwners         []string          `json:"fromOwners,omitempty" metadata:",optional"`
+	LegalReference     string            `json:"legalReference,omitempty" metadata:",optional"` // court order or acquisition notification
+	StampDuty          float64           `json:"stampDuty,omitempty" metadata:",optional"`
+	RegistrationFee    float64           `json:"registrationFee,omitempty" metadata:",optional"`
+	FeeScheduleVersion int               `json:"feeScheduleVersion,omitempty" metadata:",optional"`
+	FeePaymentRef      string            `json:"feePaymentRef,omitempty" metadata:",optional"`
+	GuidelineValue     float64           `json:"guidelineValue,omitempty" metadata:",optional"`
+	BelowGuideline     bool              `json:"belowGuideline,omitempty" metadata:",optional"`
+	Succession         *SuccessionRecord `json:"succession,omitempty" metadata:",optional"`
+	Timestamp          time.Time         `json:"timestamp"`
+	RecordedAt         string            `json:"recordedAt"` // Timestamp in sortableTimestampLayout, for exact range queries
 }
 
 // ============= User Management =============
 
 func (c *PropertyContract) RegisterUser(ctx contractapi.TransactionContextInterface, userID string, name string, email string, role string, walletAddress string, phone string) error {
+	caller, err := access.GetCaller(ctx)
+	if err != nil {
+		return err
+	}
+	// Users register themselves with the role on their certificate; only registrar
+	// admins may register other identities or assign a different role
+	if (caller.ID != userID || caller.Role != role) && !caller.IsRegistrarAdmin() {
+		return fmt.Errorf("access denied: caller %s may only register itself with its certificate role", caller.ID)
+	}
+
+	if err := access.ValidateIdentityID(userID); err != nil {
+		return err
+	}
+
 	exists, err := c.UserExists(ctx, userID)
 	if err != nil {
 		return err
@@ -87,16 +130,16 @@ func (c *PropertyContract) RegisterUser(ctx contractapi.TransactionContextInterf
 		LastLogin:     timestamp,
 	}
 
-	userJSON, err := json.Marshal(user)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState("USER_"+userID, userJSON)
+	return c.putUser(ctx, &user)
 }
 
 func (c *PropertyContract) GetUser(ctx contractapi.TransactionContextInterface, userID string) (*User, error) {
-	userJSON, err := ctx.GetStub().GetState("USER_" + userID)
+	key, err := userKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read user: %v", err)
 	}
@@ -114,32 +157,71 @@ func (c *PropertyContract) GetUser(ctx contractapi.TransactionContextInterface,
 }
 
 func (c *PropertyContract) UpdateUserVerification(ctx contractapi.TransactionContextInterface, userID string, isVerified bool) error {
-	user, err := c.GetUser(ctx, userID)
-	if err != nil {
+	if _, err := access.RequireRegistrar(ctx); err != nil {
 		return err
 	}
 
-	user.IsVerified = isVerified
-
-	userJSON, err := json.Marshal(user)
+	user, err := c.GetUser(ctx, userID)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState("USER_"+userID, userJSON)
+	user.IsVerified = isVerified
+
+	return c.putUser(ctx, user)
 }
 
 func (c *PropertyContract) UserExists(ctx contractapi.TransactionContextInterface, userID string) (bool, error) {
-	userJSON, err := ctx.GetStub().GetState("USER_" + userID)
+	key, err := userKey(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	userJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return false, fmt.Errorf("failed to read user: %v", err)
 	}
 	return userJSON != nil, nil
 }
 
+// putUser writes a user under its composite key
+func (c *PropertyContract) putUser(ctx contractapi.TransactionContextInterface, user *User) error {
+	key, err := userKey(ctx, user.UserID)
+	if err != nil {
+		return err
+	}
+
+	userJSON, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, userJSON)
+}
+
 // ============= Enhanced Property Management =============
 
-func (c *PropertyContract) RegisterProperty(ctx contractapi.TransactionContextInterface, propertyID string, owner string, ownerName string, location string, area float64, price float64, propertyType string, description string, latitude float64, longitude float64) error {
+// RegisterProperty submits a property for verification. Its type must be a
+// land use permitted in its zone.
+func (c *PropertyContract) RegisterProperty(ctx contractapi.TransactionContextInterface, propertyID string, owner string, ownerName string, location string, area float64, price float64, propertyType string, zoneCode string, description string, latitude float64, longitude float64, boundaryJSON string) error {
+	caller, err := access.GetCaller(ctx)
+	if err != nil {
+		return err
+	}
+	// Sellers register their own land; registrar admins may register on behalf of an owner
+	if !caller.IsRegistrarAdmin() {
+		if !caller.HasRole(access.RoleSeller) {
+			return fmt.Errorf("access denied: caller %s must be a SELLER to register property", caller.ID)
+		}
+		if owner != caller.ID {
+			return fmt.Errorf("access denied: caller %s cannot register property for owner %s", caller.ID, owner)
+		}
+	}
+
+	if err := access.ValidateIdentityID(owner); err != nil {
+		return err
+	}
+
 	exists, err := c.PropertyExists(ctx, propertyID)
 	if err != nil {
 		return err
@@ -148,21 +230,41 @@ func (c *PropertyContract) RegisterProperty(ctx contractapi.TransactionContextIn
 		return fmt.Errorf("property %s already exists", propertyID)
 	}
 
+	if err := validateCoordinates(latitude, longitude); err != nil {
+		return err
+	}
+	if err := c.validateLandUse(ctx, zoneCode, propertyType); err != nil {
+		return err
+	}
+
+	// The boundary is optional; when given it must not overlap land already registered
+	var boundary *GeoPolygon
+	if boundaryJSON != "" {
+		boundary, err = parseBoundary(boundaryJSON, area)
+		if err != nil {
+			return err
+		}
+		if err := c.checkParcelOverlap(ctx, propertyID, boundary); err != nil {
+			return err
+		}
+	}
+
 	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
 		return fmt.Errorf("failed to get transaction timestamp: %v", err)
 	}
 	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
 
-	property := Property{
+	property := &Property{
 		PropertyID:   propertyID,
 		Owner:        owner,
 		OwnerName:    ownerName,
 		Location:     location,
 		Area:         area,
 		Price:        price,
-		Status:       "PENDING_VERIFICATION",
+		Status:       statusPendingVerification,
 		PropertyType: propertyType,
+		ZoneCode:     zoneCode,
 		Description:  description,
 		Documents:    []string{},
 		VerifiedBy:   "",
@@ -171,46 +273,42 @@ func (c *PropertyContract) RegisterProperty(ctx contractapi.TransactionContextIn
 		Views:        0,
 		Latitude:     latitude,
 		Longitude:    longitude,
+		Boundary:     boundary,
+		Owners:       []OwnershipShare{{OwnerID: owner, OwnerName: ownerName, Share: 100}},
 	}
 
-	propertyJSON, err := json.Marshal(property)
-	if err != nil {
+	// The asking price at registration opens the property's price history
+	if err := putPriceChange(ctx, propertyID, 0, price, caller.ID, timestamp); err != nil {
 		return err
 	}
-
-	return ctx.GetStub().PutState(propertyID, propertyJSON)
-}
-
-func (c *PropertyContract) VerifyProperty(ctx contractapi.TransactionContextInterface, propertyID string, verifierID string) error {
-	property, err := c.GetProperty(ctx, propertyID)
-	if err != nil {
+	if err := putGeoIndex(ctx, property); err != nil {
 		return err
 	}
-
-	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
-	if err != nil {
-		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	if boundary != nil {
+		if err := putParcelIndex(ctx, property); err != nil {
+			return err
+		}
 	}
-	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
 
-	property.Status = "VERIFIED"
-	property.VerifiedBy = verifierID
-	property.VerifiedAt = timestamp
-	property.LastUpdated = timestamp
+	return c.putProperty(ctx, property)
+}
 
-	propertyJSON, err := json.Marshal(property)
+func (c *PropertyContract) UpdatePropertyPrice(ctx contractapi.TransactionContextInterface, propertyID string, price float64) error {
+	property, err := c.getProperty(ctx, propertyID)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(propertyID, propertyJSON)
-}
-
-func (c *PropertyContract) UpdatePropertyPrice(ctx contractapi.TransactionContextInterface, propertyID string, price float64) error {
-	property, err := c.GetProperty(ctx, propertyID)
+	caller, err := requireOwner(ctx, property)
 	if err != nil {
 		return err
 	}
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+	if price < 0 {
+		return fmt.Errorf("price cannot be negative")
+	}
 
 	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
@@ -218,35 +316,38 @@ func (c *PropertyContract) UpdatePropertyPrice(ctx contractapi.TransactionContex
 	}
 	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
 
-	property.Price = price
-	property.LastUpdated = timestamp
-
-	propertyJSON, err := json.Marshal(property)
-	if err != nil {
+	if err := putPriceChange(ctx, propertyID, property.Price, price, caller.ID, timestamp); err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(propertyID, propertyJSON)
+	property.Price = price
+	property.LastUpdated = timestamp
+
+	return c.putProperty(ctx, property)
 }
 
-func (c *PropertyContract) IncrementPropertyViews(ctx contractapi.TransactionContextInterface, propertyID string) error {
-	property, err := c.GetProperty(ctx, propertyID)
+// GetProperty returns a property with its current view count
+func (c *PropertyContract) GetProperty(ctx contractapi.TransactionContextInterface, propertyID string) (*Property, error) {
+	property, err := c.getProperty(ctx, propertyID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	property.Views++
-
-	propertyJSON, err := json.Marshal(property)
-	if err != nil {
-		return err
+	if err := withViews(ctx, property); err != nil {
+		return nil, err
 	}
 
-	return ctx.GetStub().PutState(propertyID, propertyJSON)
+	return property, nil
 }
 
-func (c *PropertyContract) GetProperty(ctx contractapi.TransactionContextInterface, propertyID string) (*Property, error) {
-	propertyJSON, err := ctx.GetStub().GetState(propertyID)
+// getProperty reads a property as stored, for transactions that rewrite it
+func (c *PropertyContract) getProperty(ctx contractapi.TransactionContextInterface, propertyID string) (*Property, error) {
+	key, err := propertyKey(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	propertyJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read property: %v", err)
 	}
@@ -264,17 +365,26 @@ func (c *PropertyContract) GetProperty(ctx contractapi.TransactionContextInterfa
 }
 
 func (c *PropertyContract) GetPropertiesByStatus(ctx contractapi.TransactionContextInterface, status string) ([]*Property, error) {
-	queryString := fmt.Sprintf(`{"selector":{"status":"%s"}}`, status)
+	queryString, err := couchquery.Build(propertySelector(map[string]interface{}{"status": status}))
+	if err != nil {
+		return nil, err
+	}
 	return c.queryProperties(ctx, queryString)
 }
 
 func (c *PropertyContract) GetPropertiesByType(ctx contractapi.TransactionContextInterface, propertyType string) ([]*Property, error) {
-	queryString := fmt.Sprintf(`{"selector":{"propertyType":"%s"}}`, propertyType)
+	queryString, err := couchquery.Build(propertySelector(map[string]interface{}{"propertyType": propertyType}))
+	if err != nil {
+		return nil, err
+	}
 	return c.queryProperties(ctx, queryString)
 }
 
 func (c *PropertyContract) GetPropertiesByOwner(ctx contractapi.TransactionContextInterface, owner string) ([]*Property, error) {
-	queryString := fmt.Sprintf(`{"selector":{"owner":"%s"}}`, owner)
+	queryString, err := couchquery.Build(propertySelector(ownerSelector(owner)))
+	if err != nil {
+		return nil, err
+	}
 	return c.queryProperties(ctx, queryString)
 }
 
@@ -291,11 +401,17 @@ func (c *PropertyContract) queryProperties(ctx contractapi.TransactionContextInt
 		if err != nil {
 			return nil, err
 		}
+		if !hasObjectType(ctx, queryResponse.Key, propertyObjectType) {
+			continue
+		}
 
 		var property Property
 		err = json.Unmarshal(queryResponse.Value, &property)
 		if err != nil {
-			continue
+			return nil, err
+		}
+		if err := withCompactedViews(ctx, &property); err != nil {
+			return nil, err
 		}
 		properties = append(properties, &property)
 	}
@@ -304,7 +420,23 @@ func (c *PropertyContract) queryProperties(ctx contractapi.TransactionContextInt
 }
 
 func (c *PropertyContract) GetAllProperties(ctx contractapi.TransactionContextInterface) ([]*Property, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	properties, err := c.allProperties(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, property := range properties {
+		if err := withCompactedViews(ctx, property); err != nil {
+			return nil, err
+		}
+	}
+
+	return properties, nil
+}
+
+// allProperties reads every property as stored
+func (c *PropertyContract) allProperties(ctx contractapi.TransactionContextInterface) ([]*Property, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(propertyObjectType, []string{})
 	if err != nil {
 		return nil, err
 	}
@@ -320,7 +452,7 @@ func (c *PropertyContract) GetAllProperties(ctx contractapi.TransactionContextIn
 		var property Property
 		err = json.Unmarshal(queryResponse.Value, &property)
 		if err != nil {
-			continue
+			return nil, err
 		}
 		properties = append(properties, &property)
 	}
@@ -328,58 +460,203 @@ func (c *PropertyContract) GetAllProperties(ctx contractapi.TransactionContextIn
 	return properties, nil
 }
 
-func (c *PropertyContract) TransferProperty(ctx contractapi.TransactionContextInterface, propertyID string, newOwner string, newOwnerName string, transactionID string) error {
-	property, err := c.GetProperty(ctx, propertyID)
+// TransferProperty transfers title for the consideration actually paid. Sales
+// and gifts are made by an owner, and every co-owner must consent; a registrar
+// closes sales only through SettleSale. Court-ordered transfers and government
+// acquisitions are recorded by a registrar admin against legalReference. When the fee schedule charges duty on the transfer,
+// feePaymentRef must reference its payment.
+func (c *PropertyContract) TransferProperty(ctx contractapi.TransactionContextInterface, propertyID string, newOwner string, newOwnerName string, transactionID string, transferType string, consideration float64, legalReference string, feePaymentRef string) error {
+	property, err := c.getProperty(ctx, propertyID)
 	if err != nil {
 		return err
 	}
 
-	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
-	if err != nil {
-		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	terms := &transferTerms{
+		TransferType:   transferType,
+		Consideration:  consideration,
+		FeePaymentRef:  feePaymentRef,
+		LegalReference: legalReference,
+	}
+	if err := validateTransferTerms(terms); err != nil {
+		return err
+	}
+	if err := access.ValidateIdentityID(newOwner); err != nil {
+		return err
 	}
-	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
 
-	oldOwner := property.Owner
-	property.Owner = newOwner
-	property.OwnerName = newOwnerName
-	property.Status = "SOLD"
-	property.LastUpdated = timestamp
+	if isVoluntaryTransfer(transferType) {
+		if _, err := requireOwner(ctx, property); err != nil {
+			return err
+		}
+		if !property.hasSaleConsent(newOwner) {
+			return fmt.Errorf("all co-owners of property %s must consent to the transfer to %s", propertyID, newOwner)
+		}
+	} else if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return err
+	}
+
+	if transferType == transferSale {
+		if err := validateTransition(propertyID, property.Status, statusSold); err != nil {
+			return err
+		}
+	} else if !isTransferableStatus(property.Status) {
+		return fmt.Errorf("property %s cannot be transferred while it is %s", propertyID, property.Status)
+	}
 
-	propertyJSON, err := json.Marshal(property)
+	return c.transferTitle(ctx, property, newOwner, newOwnerName, transactionID, terms)
+}
+
+// transferTitle moves a property to its new owner and writes the transaction
+// record with the duty and fees owed, which must have been paid. A sale leaves
+// the property SOLD; after any other transfer a listing is withdrawn.
+func (c *PropertyContract) transferTitle(ctx contractapi.TransactionContextInterface, property *Property, newOwner string, newOwnerName string, transactionID string, terms *transferTerms) error {
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+	if err := c.requireUnencumbered(ctx, property.PropertyID); err != nil {
+		return err
+	}
+
+	// Government acquisitions are exempt from duty; gifts pay it on the guideline value
+	fees := &TransferFeeEstimate{}
+	if terms.TransferType != transferGovernmentAcquisition {
+		var err error
+		fees, err = c.transferFees(ctx, property, terms.Consideration, 100)
+		if err != nil {
+			return err
+		}
+	}
+	if fees.TotalFees > 0 && terms.FeePaymentRef == "" {
+		return fmt.Errorf("transfer of property %s owes %.2f in stamp duty and registration fees; a fee payment reference is required", property.PropertyID, fees.TotalFees)
+	}
+
+	transaction, err := newTransaction(ctx, transactionID, property.PropertyID)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(propertyID, propertyJSON)
+	for _, owner := range property.ownerShares() {
+		transaction.FromOwners = append(transaction.FromOwners, owner.OwnerID)
+	}
+	transaction.TransferType = terms.TransferType
+	transaction.FromOwner = property.Owner
+	transaction.ToOwner = newOwner
+	transaction.Amount = terms.Consideration
+	transaction.EscrowID = terms.EscrowID
+	transaction.LegalReference = terms.LegalReference
+	transaction.Share = 100
+	transaction.StampDuty = fees.StampDuty
+	transaction.RegistrationFee = fees.RegistrationFee
+	transaction.FeeScheduleVersion = fees.FeeScheduleVersion
+	transaction.FeePaymentRef = terms.FeePaymentRef
+	transaction.GuidelineValue = fees.GuidelineValue
+	transaction.BelowGuideline = fees.BelowGuideline && terms.TransferType == transferSale
+
+	property.setOwners([]OwnershipShare{{OwnerID: newOwner, OwnerName: newOwnerName, Share: 100}})
+	property.SaleConsents = nil
+	if terms.TransferType == transferSale {
+		property.Status = statusSold
+	} else if property.Status == statusAvailable {
+		property.Status = statusWithdrawn
+	}
+	property.LastUpdated = transaction.Timestamp
+
+	err = c.putProperty(ctx, property)
 	if err != nil {
 		return err
 	}
 
-	// Create transaction record
-	transaction := Transaction{
+	return putTransaction(ctx, transaction)
+}
+
+// newTransaction starts a completed transaction record stamped with the
+// transaction time, rejecting IDs that are already recorded
+func newTransaction(ctx contractapi.TransactionContextInterface, transactionID string, propertyID string) (*Transaction, error) {
+	key, err := transactionKey(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	existingJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transaction: %v", err)
+	}
+	if existingJSON != nil {
+		return nil, fmt.Errorf("transaction %s already exists", transactionID)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	return &Transaction{
 		TransactionID: transactionID,
 		PropertyID:    propertyID,
-		FromOwner:     oldOwner,
-		ToOwner:       newOwner,
-		Amount:        property.Price,
 		Status:        "COMPLETED",
-		Timestamp:     timestamp,
+		Timestamp:     time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)),
+	}, nil
+}
+
+// putTransaction writes a transaction record under its composite key
+func putTransaction(ctx contractapi.TransactionContextInterface, transaction *Transaction) error {
+	key, err := transactionKey(ctx, transaction.TransactionID)
+	if err != nil {
+		return err
 	}
 
+	transaction.DocType = transactionObjectType
+	transaction.RecordedAt = sortableTimestamp(transaction.Timestamp)
+
 	transactionJSON, err := json.Marshal(transaction)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState("TXN_"+transactionID, transactionJSON)
+	return ctx.GetStub().PutState(key, transactionJSON)
+}
+
+// transactionsForProperty returns the transaction records of a property. Records
+// written before docType existed are found once BackfillDocTypes has run.
+func transactionsForProperty(ctx contractapi.TransactionContextInterface, propertyID string) ([]*Transaction, error) {
+	queryString, err := couchquery.Build(transactionSelector(map[string]interface{}{"propertyId": propertyID}))
+	if err != nil {
+		return nil, err
+	}
+
+	return queryTransactions(ctx, queryString)
 }
 
 func (c *PropertyContract) UpdatePropertyStatus(ctx contractapi.TransactionContextInterface, propertyID string, status string) error {
-	property, err := c.GetProperty(ctx, propertyID)
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	caller, err := requireOwnerOrRegistrarAdmin(ctx, property)
 	if err != nil {
 		return err
 	}
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+	// Only the owner may put a property on the market
+	if status == statusAvailable && !property.isOwner(caller.ID) {
+		return fmt.Errorf("access denied: only the owner of property %s may list it", propertyID)
+	}
+
+	// Review, sale and lineage carry extra bookkeeping and have dedicated transactions
+	if transaction, ok := dedicatedTransactions[status]; ok {
+		return newContractError(errCodeInvalidTransition, "status %s can only be set by %s", status, transaction)
+	}
+	if err := validateTransition(propertyID, property.Status, status); err != nil {
+		return err
+	}
+	if property.Status == statusUnderContract {
+		if err := requireNoOpenContract(ctx, propertyID); err != nil {
+			return err
+		}
+	}
 
 	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
@@ -390,30 +667,129 @@ func (c *PropertyContract) UpdatePropertyStatus(ctx contractapi.TransactionConte
 	property.Status = status
 	property.LastUpdated = timestamp
 
-	propertyJSON, err := json.Marshal(property)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(propertyID, propertyJSON)
+	return c.putProperty(ctx, property)
 }
 
 func (c *PropertyContract) PropertyExists(ctx contractapi.TransactionContextInterface, propertyID string) (bool, error) {
-	propertyJSON, err := ctx.GetStub().GetState(propertyID)
+	key, err := propertyKey(ctx, propertyID)
+	if err != nil {
+		return false, err
+	}
+
+	propertyJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return false, fmt.Errorf("failed to read property: %v", err)
 	}
 	return propertyJSON != nil, nil
 }
 
+// propertyRecord is one committed version of a property
+type propertyRecord struct {
+	Modification *queryresult.KeyModification
+	Property     Property
+}
+
+// GetPropertyHistory returns the history of a property followed by the history
+// of the parcels it was subdivided or merged from, so title can be traced back
+// across splits and mergers
 func (c *PropertyContract) GetPropertyHistory(ctx contractapi.TransactionContextInterface, propertyID string) ([]map[string]interface{}, error) {
-	resultsIterator, err := ctx.GetStub().GetHistoryForKey(propertyID)
+	records, err := c.lineageHistory(ctx, propertyID)
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
 
 	var history []map[string]interface{}
+	for _, record := range records {
+		history = append(history, map[string]interface{}{
+			"txId":      record.Modification.TxId,
+			"timestamp": record.Modification.Timestamp,
+			"property":  record.Property,
+			"isDelete":  record.Modification.IsDelete,
+		})
+	}
+
+	return history, nil
+}
+
+// lineageHistory returns the history of a property followed by that of its ancestors
+func (c *PropertyContract) lineageHistory(ctx contractapi.TransactionContextInterface, propertyID string) ([]propertyRecord, error) {
+	propertyIDs, err := c.propertyLineage(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []propertyRecord
+	for _, id := range propertyIDs {
+		records, err := c.propertyKeyHistory(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, records...)
+	}
+
+	return history, nil
+}
+
+// propertyLineage returns a property's ID followed by the IDs of the parcels it
+// was subdivided or merged from, nearest first
+func (c *PropertyContract) propertyLineage(ctx contractapi.TransactionContextInterface, propertyID string) ([]string, error) {
+	lineage := []string{propertyID}
+	visited := map[string]bool{propertyID: true}
+	for i := 0; i < len(lineage); i++ {
+		exists, err := c.PropertyExists(ctx, lineage[i])
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		property, err := c.getProperty(ctx, lineage[i])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, parentID := range property.ParentIDs {
+			if !visited[parentID] {
+				visited[parentID] = true
+				lineage = append(lineage, parentID)
+			}
+		}
+	}
+
+	return lineage, nil
+}
+
+// propertyKeyHistory returns the history of one property, newest first
+func (c *PropertyContract) propertyKeyHistory(ctx contractapi.TransactionContextInterface, propertyID string) ([]propertyRecord, error) {
+	key, err := propertyKey(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := c.keyHistory(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Properties written before MigrateKeys keep their older history under the
+	// plain ID; history is newest first, so it follows the namespaced records
+	legacyHistory, err := c.keyHistory(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(history, legacyHistory...), nil
+}
+
+// keyHistory returns the history records of the property stored under a ledger key
+func (c *PropertyContract) keyHistory(ctx contractapi.TransactionContextInterface, key string) ([]propertyRecord, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var history []propertyRecord
 	for resultsIterator.HasNext() {
 		response, err := resultsIterator.Next()
 		if err != nil {
@@ -428,13 +804,10 @@ func (c *PropertyContract) GetPropertyHistory(ctx contractapi.TransactionContext
 			}
 		}
 
-		record := map[string]interface{}{
-			"txId":      response.TxId,
-			"timestamp": response.Timestamp,
-			"property":  property,
-			"isDelete":  response.IsDelete,
-		}
-		history = append(history, record)
+		history = append(history, propertyRecord{
+			Modification: response,
+			Property:     property,
+		})
 	}
 
 	return history, nil
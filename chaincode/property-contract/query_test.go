@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+// decodeSelector parses a query string and returns its selector, failing if
+// the query has fields other than the selector and sort order
+func decodeSelector(t *testing.T, queryString string) map[string]interface{} {
+	t.Helper()
+
+	var query map[string]interface{}
+	if err := json.Unmarshal([]byte(queryString), &query); err != nil {
+		t.Fatalf("query %s is not valid JSON: %v", queryString, err)
+	}
+	for field := range query {
+		if field != "selector" && field != "sort" {
+			t.Fatalf("query %s gained field %q", queryString, field)
+		}
+	}
+	selector, ok := query["selector"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("query %s has no selector object", queryString)
+	}
+	return selector
+}
+
+// requireFields fails unless the selector has exactly the given fields
+func requireFields(t *testing.T, selector map[string]interface{}, fields ...string) {
+	t.Helper()
+
+	if len(selector) != len(fields) {
+		t.Fatalf("selector %v has %d fields, want %v", selector, len(selector), fields)
+	}
+	for _, field := range fields {
+		if _, ok := selector[field]; !ok {
+			t.Fatalf("selector %v is missing %q", selector, field)
+		}
+	}
+}
+
+func TestSearchQuerySelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		criteria SearchCriteria
+		want     map[string]interface{}
+	}{
+		{
+			name:     "no criteria",
+			criteria: SearchCriteria{},
+			want:     map[string]interface{}{"docType": propertyObjectType},
+		},
+		{
+			name:     "type and status",
+			criteria: SearchCriteria{PropertyType: "RESIDENTIAL", Status: "VERIFIED"},
+			want: map[string]interface{}{
+				"docType":      propertyObjectType,
+				"propertyType": "RESIDENTIAL",
+				"status":       "VERIFIED",
+			},
+		},
+		{
+			name:     "verified price and area ranges",
+			criteria: SearchCriteria{VerifiedOnly: true, MinPrice: 100, MaxPrice: 500, MinArea: 20},
+			want: map[string]interface{}{
+				"docType":    propertyObjectType,
+				"verifiedBy": map[string]interface{}{"$gt": ""},
+				"price":      map[string]interface{}{"$gte": 100.0, "$lte": 500.0},
+				"area":       map[string]interface{}{"$gte": 20.0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queryString, err := buildSearchQuery(&tt.criteria)
+			if err != nil {
+				t.Fatalf("buildSearchQuery: %v", err)
+			}
+			if selector := decodeSelector(t, queryString); !reflect.DeepEqual(selector, tt.want) {
+				t.Fatalf("selector = %#v, want %#v", selector, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchQueryMatchesLocationLiterally(t *testing.T) {
+	for _, input := range []string{"MG Road", ".*", "Plot 12 (A)", `^(a|b)+$[]{}?\`} {
+		t.Run(input, func(t *testing.T) {
+			queryString, err := buildSearchQuery(&SearchCriteria{Location: input})
+			if err != nil {
+				t.Fatalf("buildSearchQuery: %v", err)
+			}
+
+			selector := decodeSelector(t, queryString)
+			requireFields(t, selector, "docType", "location")
+			condition, ok := selector["location"].(map[string]interface{})
+			if !ok || len(condition) != 1 {
+				t.Fatalf("location = %#v, want a single $regex condition", selector["location"])
+			}
+			pattern, ok := condition["$regex"].(string)
+			if !ok {
+				t.Fatalf("location $regex = %#v, want a string", condition["$regex"])
+			}
+
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				t.Fatalf("pattern %q does not compile: %v", pattern, err)
+			}
+			if !re.MatchString("Near " + input + " junction") {
+				t.Fatalf("pattern %q does not match a location containing %q", pattern, input)
+			}
+			if re.MatchString("unrelated location") {
+				t.Fatalf("pattern %q matches a location without %q", pattern, input)
+			}
+		})
+	}
+}
+
+func TestSearchQueryRejectsInvalidCriteria(t *testing.T) {
+	tests := []struct {
+		name     string
+		criteria SearchCriteria
+	}{
+		{"unknown status", SearchCriteria{Status: "SOLD_OUT"}},
+		{"unknown sort field", SearchCriteria{SortBy: "views"}},
+		{"unknown sort order", SearchCriteria{SortBy: "price", SortOrder: "up"}},
+		{"negative bound", SearchCriteria{MinArea: -1}},
+		{"inverted price range", SearchCriteria{MinPrice: 500, MaxPrice: 100}},
+		{"inverted area range", SearchCriteria{MinArea: 50, MaxArea: 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if queryString, err := buildSearchQuery(&tt.criteria); err == nil {
+				t.Fatalf("buildSearchQuery = %s, want an error", queryString)
+			}
+		})
+	}
+}
+
+func TestSearchQuerySort(t *testing.T) {
+	tests := []struct {
+		criteria SearchCriteria
+		field    string
+		order    string
+	}{
+		{SearchCriteria{SortBy: "price", SortOrder: "desc"}, "price", "desc"},
+		{SearchCriteria{SortBy: "registeredAt"}, "registeredAtKey", "asc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.criteria.SortBy, func(t *testing.T) {
+			queryString, err := buildSearchQuery(&tt.criteria)
+			if err != nil {
+				t.Fatalf("buildSearchQuery: %v", err)
+			}
+
+			var query struct {
+				Sort []map[string]string `json:"sort"`
+			}
+			if err := json.Unmarshal([]byte(queryString), &query); err != nil {
+				t.Fatalf("query %s is not valid JSON: %v", queryString, err)
+			}
+			if want := []map[string]string{{tt.field: tt.order}}; !reflect.DeepEqual(query.Sort, want) {
+				t.Fatalf("sort = %v, want %v", query.Sort, want)
+			}
+			requireFields(t, decodeSelector(t, queryString), "docType", tt.field)
+		})
+	}
+}
+
+func TestOwnerSelector(t *testing.T) {
+	want := map[string]interface{}{
+		"$or": []interface{}{
+			map[string]interface{}{"owner": "Org1MSP::alice"},
+			map[string]interface{}{"owners": map[string]interface{}{
+				"$elemMatch": map[string]interface{}{"ownerId": "Org1MSP::alice"},
+			}},
+		},
+	}
+	if selector := ownerSelector("Org1MSP::alice"); !reflect.DeepEqual(selector, want) {
+		t.Fatalf("ownerSelector = %#v, want %#v", selector, want)
+	}
+}
+
+func TestPartySelector(t *testing.T) {
+	want := map[string]interface{}{
+		"docType": transactionObjectType,
+		"$or": []interface{}{
+			map[string]interface{}{"fromOwner": "Org1MSP::alice"},
+			map[string]interface{}{"toOwner": "Org1MSP::alice"},
+			map[string]interface{}{"fromOwners": map[string]interface{}{"$elemMatch": map[string]interface{}{"$eq": "Org1MSP::alice"}}},
+			map[string]interface{}{"succession.heirs": map[string]interface{}{
+				"$elemMatch": map[string]interface{}{"ownerId": "Org1MSP::alice"},
+			}},
+		},
+	}
+	if selector := partySelector("Org1MSP::alice"); !reflect.DeepEqual(selector, want) {
+		t.Fatalf("partySelector = %#v, want %#v", selector, want)
+	}
+}
+
+func TestDateRangeSelectorComparesRecordedAt(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 31, 23, 59, 59, 500000000, time.FixedZone("IST", 5*3600+1800))
+
+	want := map[string]interface{}{
+		"docType": transactionObjectType,
+		"recordedAt": map[string]interface{}{
+			"$gte": "2024-03-01T00:00:00.000000000Z",
+			"$lte": "2024-03-31T18:29:59.500000000Z",
+		},
+	}
+	if selector := dateRangeSelector(from, to); !reflect.DeepEqual(selector, want) {
+		t.Fatalf("dateRangeSelector = %#v, want %#v", selector, want)
+	}
+}
+
+func TestSortableTimestampSortsLikeTime(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	times := []time.Time{
+		time.Date(2024, 3, 1, 10, 0, 5, 0, time.UTC),
+		time.Date(2024, 3, 1, 10, 0, 5, 500000000, time.UTC),
+		time.Date(2024, 3, 1, 10, 0, 5, 123, time.UTC),
+		time.Date(2024, 3, 1, 15, 30, 4, 999999999, ist),
+		time.Date(2024, 3, 1, 10, 0, 6, 0, time.UTC),
+		time.Date(2024, 3, 1, 15, 30, 6, 1, ist),
+	}
+
+	formatted := make([]string, len(times))
+	for i, recorded := range times {
+		formatted[i] = sortableTimestamp(recorded)
+		if _, err := time.Parse(time.RFC3339Nano, formatted[i]); err != nil {
+			t.Fatalf("%q is not RFC 3339: %v", formatted[i], err)
+		}
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	sort.Strings(formatted)
+	for i, recorded := range times {
+		if formatted[i] != sortableTimestamp(recorded) {
+			t.Fatalf("string order %v does not match time order at %d (%s)", formatted, i, recorded)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"couchquery"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// SearchCriteria filters a property search. Zero values leave a criterion unset.
+type SearchCriteria struct {
+	Location     string  `json:"location"` // case-insensitive substring
+	PropertyType string  `json:"propertyType"`
+	MinPrice     float64 `json:"minPrice"`
+	MaxPrice     float64 `json:"maxPrice"`
+	MinArea      float64 `json:"minArea"`
+	MaxArea      float64 `json:"maxArea"`
+	Status       string  `json:"status"`
+	VerifiedOnly bool    `json:"verifiedOnly"`
+	SortBy       string  `json:"sortBy"`    // price, registeredAt
+	SortOrder    string  `json:"sortOrder"` // asc (default), desc
+}
+
+// SearchProperties retrieves one page of properties matching a JSON-encoded SearchCriteria
+func (c *PropertyContract) SearchProperties(ctx contractapi.TransactionContextInterface, criteriaJSON string, pageSize int32, bookmark string) (*PaginatedPropertyResult, error) {
+	var criteria SearchCriteria
+	err := json.Unmarshal([]byte(criteriaJSON), &criteria)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search criteria: %v", err)
+	}
+
+	queryString, err := buildSearchQuery(&criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.queryPropertiesWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// buildSearchQuery translates search criteria into a sorted CouchDB query
+func buildSearchQuery(criteria *SearchCriteria) (string, error) {
+	if criteria.MinPrice < 0 || criteria.MaxPrice < 0 || criteria.MinArea < 0 || criteria.MaxArea < 0 {
+		return "", fmt.Errorf("price and area bounds must not be negative")
+	}
+	if criteria.MaxPrice > 0 && criteria.MinPrice > criteria.MaxPrice {
+		return "", fmt.Errorf("minPrice %.2f exceeds maxPrice %.2f", criteria.MinPrice, criteria.MaxPrice)
+	}
+	if criteria.MaxArea > 0 && criteria.MinArea > criteria.MaxArea {
+		return "", fmt.Errorf("minArea %.2f exceeds maxArea %.2f", criteria.MinArea, criteria.MaxArea)
+	}
+	if criteria.Status != "" && !isKnownStatus(criteria.Status) {
+		return "", newContractError(errCodeInvalidStatus, "unknown property status %q", criteria.Status)
+	}
+
+	selector := propertySelector(map[string]interface{}{})
+	if criteria.Location != "" {
+		selector["location"] = map[string]interface{}{"$regex": "(?i)" + regexp.QuoteMeta(criteria.Location)}
+	}
+	if criteria.PropertyType != "" {
+		selector["propertyType"] = criteria.PropertyType
+	}
+	if criteria.Status != "" {
+		selector["status"] = criteria.Status
+	}
+	if criteria.VerifiedOnly {
+		selector["verifiedBy"] = map[string]interface{}{"$gt": ""}
+	}
+	if priceRange := rangeCondition(criteria.MinPrice, criteria.MaxPrice); priceRange != nil {
+		selector["price"] = priceRange
+	}
+	if areaRange := rangeCondition(criteria.MinArea, criteria.MaxArea); areaRange != nil {
+		selector["area"] = areaRange
+	}
+
+	if criteria.SortBy == "" {
+		return couchquery.Build(selector)
+	}
+
+	order := criteria.SortOrder
+	if order == "" {
+		order = "asc"
+	}
+	if order != "asc" && order != "desc" {
+		return "", fmt.Errorf("sortOrder must be asc or desc, got %q", criteria.SortOrder)
+	}
+
+	// CouchDB only sorts on fields the selector constrains
+	sortField := criteria.SortBy
+	switch criteria.SortBy {
+	case "price":
+		if _, ok := selector["price"]; !ok {
+			selector["price"] = map[string]interface{}{"$gte": 0}
+		}
+	case "registeredAt":
+		// RFC 3339 times drop trailing zeros of the fraction, so their strings
+		// do not sort in time order; the fixed-width key does
+		sortField = "registeredAtKey"
+		selector[sortField] = map[string]interface{}{"$gt": ""}
+	default:
+		return "", fmt.Errorf("sortBy must be price or registeredAt, got %q", criteria.SortBy)
+	}
+
+	return couchquery.BuildSorted(selector, []map[string]string{{sortField: order}})
+}
+
+// rangeCondition builds a $gte/$lte condition, or nil when neither bound is set
+func rangeCondition(min float64, max float64) map[string]interface{} {
+	condition := map[string]interface{}{}
+	if min > 0 {
+		condition["$gte"] = min
+	}
+	if max > 0 {
+		condition["$lte"] = max
+	}
+	if len(condition) == 0 {
+		return nil
+	}
+	return condition
+}
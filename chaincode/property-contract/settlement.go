@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"access"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Chaincodes settled together with a property transfer. Both are installed on the
+// same channel, so their writes join this transaction's read/write set.
+const (
+	offerChaincodeName  = "offer-contract"
+	escrowChaincodeName = "escrow-contract"
+)
+
+// weiPerUnit converts offer and escrow amounts, held in Wei, to the currency
+// units title transfers and fees are recorded in
+const weiPerUnit = 1e18
+
+// offerState mirrors the fields of an offer-contract Offer needed for settlement
+type offerState struct {
+	OfferID     string  `json:"offerId"`
+	PropertyID  string  `json:"propertyId"`
+	BuyerID     string  `json:"buyerId"`
+	BuyerName   string  `json:"buyerName"`
+	SellerID    string  `json:"sellerId"`
+	OfferAmount float64 `json:"offerAmount"`
+	Status      string  `json:"status"`
+}
+
+// escrowState mirrors the fields of an escrow-contract Escrow needed for settlement
+type escrowState struct {
+	EscrowID   string  `json:"escrowId"`
+	PropertyID string  `json:"propertyId"`
+	Buyer      string  `json:"buyer"`
+	Seller     string  `json:"seller"`
+	Amount     float64 `json:"amount"`
+	Status     string  `json:"status"`
+}
+
+// SettleSale closes a deal in a single endorsed transaction: it checks that the
+// offer is ADMIN_VERIFIED and the escrow is FUNDED for the same property, parties
+// and amount, then transfers title, completes the offer and releases the escrow.
+// Any failure aborts the whole transaction, so the ledger never holds a
+// half-finished closing. The consideration recorded is the offer amount in
+// currency units, and feePaymentRef references the payment of the duty and fees
+// owed on it.
+func (c *PropertyContract) SettleSale(ctx contractapi.TransactionContextInterface, offerID string, escrowID string, transactionID string, releaseTxHash string, feePaymentRef string) error {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return err
+	}
+
+	var offer offerState
+	if err := invokeChaincode(ctx, offerChaincodeName, &offer, "GetOffer", offerID); err != nil {
+		return err
+	}
+	if offer.Status != "ADMIN_VERIFIED" {
+		return fmt.Errorf("offer %s must be ADMIN_VERIFIED before settlement, found %s", offerID, offer.Status)
+	}
+
+	var escrow escrowState
+	if err := invokeChaincode(ctx, escrowChaincodeName, &escrow, "GetEscrow", escrowID); err != nil {
+		return err
+	}
+	if escrow.Status != "FUNDED" {
+		return fmt.Errorf("escrow %s must be FUNDED before settlement, found %s", escrowID, escrow.Status)
+	}
+	if escrow.PropertyID != offer.PropertyID {
+		return fmt.Errorf("escrow %s is for property %s but offer %s is for property %s", escrowID, escrow.PropertyID, offerID, offer.PropertyID)
+	}
+	if escrow.Amount != offer.OfferAmount {
+		return fmt.Errorf("escrow %s holds %.2f but offer %s is for %.2f", escrowID, escrow.Amount, offerID, offer.OfferAmount)
+	}
+	if escrow.Buyer != offer.BuyerID || escrow.Seller != offer.SellerID {
+		return fmt.Errorf("escrow %s parties do not match offer %s", escrowID, offerID)
+	}
+
+	property, err := c.getProperty(ctx, offer.PropertyID)
+	if err != nil {
+		return err
+	}
+	if !property.isOwner(offer.SellerID) {
+		return fmt.Errorf("seller %s of offer %s does not own property %s", offer.SellerID, offerID, property.PropertyID)
+	}
+	if !property.hasSaleConsent(offer.BuyerID) {
+		return fmt.Errorf("all co-owners of property %s must consent to the sale to %s", property.PropertyID, offer.BuyerID)
+	}
+	if err := validateTransition(property.PropertyID, property.Status, statusSold); err != nil {
+		return err
+	}
+
+	err = c.transferTitle(ctx, property, offer.BuyerID, offer.BuyerName, transactionID, &transferTerms{
+		TransferType:  transferSale,
+		Consideration: offer.OfferAmount / weiPerUnit,
+		EscrowID:      escrowID,
+		FeePaymentRef: feePaymentRef,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := invokeChaincode(ctx, offerChaincodeName, nil, "CompleteOffer", offerID); err != nil {
+		return err
+	}
+
+	return invokeChaincode(ctx, escrowChaincodeName, nil, "ReleaseEscrow", escrowID, releaseTxHash)
+}
+
+// PlaceUnderContract moves a listed property to UNDER_CONTRACT as the seller
+// accepts an offer for it. The offer contract's AcceptOffer calls it in the
+// same transaction, under the seller's identity, while the offer is PENDING.
+func (c *PropertyContract) PlaceUnderContract(ctx contractapi.TransactionContextInterface, offerID string) error {
+	var offer offerState
+	if err := invokeChaincode(ctx, offerChaincodeName, &offer, "GetOffer", offerID); err != nil {
+		return err
+	}
+	if offer.Status != "PENDING" {
+		return fmt.Errorf("offer %s must be PENDING to be accepted, found %s", offerID, offer.Status)
+	}
+
+	property, err := c.getProperty(ctx, offer.PropertyID)
+	if err != nil {
+		return err
+	}
+	if _, err := requireOwner(ctx, property); err != nil {
+		return err
+	}
+	if !property.isOwner(offer.SellerID) {
+		return fmt.Errorf("seller %s of offer %s does not own property %s", offer.SellerID, offerID, property.PropertyID)
+	}
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+	if err := validateTransition(property.PropertyID, property.Status, statusUnderContract); err != nil {
+		return err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	property.Status = statusUnderContract
+	property.LastUpdated = time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	return c.putProperty(ctx, property)
+}
+
+// requireNoOpenContract rejects taking a property out of UNDER_CONTRACT while
+// an offer on it is accepted or verified, or a buyer's escrow for it is funded
+func requireNoOpenContract(ctx contractapi.TransactionContextInterface, propertyID string) error {
+	var offers []offerState
+	if err := invokeChaincode(ctx, offerChaincodeName, &offers, "GetOffersByProperty", propertyID); err != nil {
+		return err
+	}
+	for _, offer := range offers {
+		if offer.Status == "ACCEPTED" || offer.Status == "ADMIN_VERIFIED" {
+			return newContractError(errCodeInvalidTransition, "property %s is under contract by %s offer %s", propertyID, offer.Status, offer.OfferID)
+		}
+	}
+
+	var escrows []escrowState
+	if err := invokeChaincode(ctx, escrowChaincodeName, &escrows, "GetEscrowsByProperty", propertyID); err != nil {
+		return err
+	}
+	for _, escrow := range escrows {
+		if escrow.Status == "FUNDED" {
+			return newContractError(errCodeInvalidTransition, "property %s is under contract with funded escrow %s", propertyID, escrow.EscrowID)
+		}
+	}
+
+	return nil
+}
+
+// invokeChaincode calls a function on another chaincode on this channel and
+// decodes its JSON payload into result when result is non-nil
+func invokeChaincode(ctx contractapi.TransactionContextInterface, chaincodeName string, result interface{}, function string, args ...string) error {
+	invokeArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		invokeArgs = append(invokeArgs, []byte(arg))
+	}
+
+	response := ctx.GetStub().InvokeChaincode(chaincodeName, invokeArgs, "")
+	if response.Status >= shim.ERRORTHRESHOLD {
+		return fmt.Errorf("%s %s failed: %s", chaincodeName, function, response.Message)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	err := json.Unmarshal(response.Payload, result)
+	if err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %v", chaincodeName, function, err)
+	}
+
+	return nil
+}
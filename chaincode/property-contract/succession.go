@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// SuccessionRecord is the legal instrument under which a deceased owner's share
+// passed to their heirs
+type SuccessionRecord struct {
+	DeceasedOwnerID    string           `json:"deceasedOwnerId"`
+	InstrumentType     string           `json:"instrumentType"` // SUCCESSION_CERTIFICATE, WILL, PROBATE, etc.
+	DocumentHash       string           `json:"documentHash"`   // lowercase hex SHA-256 of the instrument
+	AuthorityReference string           `json:"authorityReference"`
+	Heirs              []OwnershipShare `json:"heirs"` // shares are percentages of the deceased's share
+	ApprovedBy         string           `json:"approvedBy"`
+}
+
+// SuccessionTransfer passes a deceased owner's share of a property to their
+// heirs under a succession certificate, will or similar instrument. It bypasses
+// offers and escrow and is submitted by a registrar, whose signature approves
+// it. heirsJSON lists the heirs with percentages of the deceased's share that
+// sum to 100. Encumbrances and leases stay attached to the title, and a listing
+// made by the deceased is withdrawn.
+func (c *PropertyContract) SuccessionTransfer(ctx contractapi.TransactionContextInterface, propertyID string, transactionID string, deceasedOwnerID string, heirsJSON string, instrumentType string, documentHash string, authorityReference string) error {
+	caller, err := access.RequireRegistrar(ctx)
+	if err != nil {
+		return err
+	}
+
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	if !isTransferableStatus(property.Status) {
+		return fmt.Errorf("property %s cannot pass by succession while it is %s", propertyID, property.Status)
+	}
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+	if property.isOwner(caller.ID) {
+		return fmt.Errorf("access denied: caller %s cannot approve a succession to its own property %s", caller.ID, propertyID)
+	}
+
+	if instrumentType == "" || authorityReference == "" {
+		return fmt.Errorf("instrument type and issuing authority reference are required")
+	}
+	documentHash, err = normalizeDocumentHash(documentHash)
+	if err != nil {
+		return err
+	}
+
+	var heirs []OwnershipShare
+	err = json.Unmarshal([]byte(heirsJSON), &heirs)
+	if err != nil {
+		return fmt.Errorf("invalid heirs: %v", err)
+	}
+	if err := validateOwnerShares(heirs); err != nil {
+		return err
+	}
+
+	for _, heir := range heirs {
+		if heir.OwnerID == deceasedOwnerID {
+			return fmt.Errorf("the deceased owner cannot be an heir")
+		}
+		if heir.OwnerID == caller.ID {
+			return fmt.Errorf("access denied: caller %s cannot approve a succession to itself", caller.ID)
+		}
+	}
+
+	owners, deceasedShare := inheritShare(property.ownerShares(), deceasedOwnerID, heirs)
+	if deceasedShare == 0 {
+		return fmt.Errorf("%s holds no share of property %s", deceasedOwnerID, propertyID)
+	}
+
+	transaction, err := newTransaction(ctx, transactionID, propertyID)
+	if err != nil {
+		return err
+	}
+	transaction.TransferType = transferSuccession
+	transaction.FromOwner = deceasedOwnerID
+	transaction.FromOwners = []string{deceasedOwnerID}
+	transaction.Share = deceasedShare
+	transaction.Succession = &SuccessionRecord{
+		DeceasedOwnerID:    deceasedOwnerID,
+		InstrumentType:     instrumentType,
+		DocumentHash:       documentHash,
+		AuthorityReference: authorityReference,
+		Heirs:              heirs,
+		ApprovedBy:         caller.ID,
+	}
+
+	primaryHeir := heirs[0]
+	for _, heir := range heirs[1:] {
+		if heir.Share > primaryHeir.Share+shareEpsilon {
+			primaryHeir = heir
+		}
+	}
+	transaction.ToOwner = primaryHeir.OwnerID
+
+	if property.Status == statusAvailable {
+		property.Status = statusWithdrawn
+	}
+	property.setOwners(owners)
+	property.SaleConsents = nil
+	property.LastUpdated = transaction.Timestamp
+
+	err = c.putProperty(ctx, property)
+	if err != nil {
+		return err
+	}
+
+	return putTransaction(ctx, transaction)
+}
+
+// inheritShare returns the owners after a deceased owner's share passes to the
+// heirs, split by their percentages, along with the share the deceased held.
+// Surviving co-owners keep their shares; heirs who already hold one add to it.
+// The deceased holding nothing leaves the owners unchanged and returns zero.
+func inheritShare(owners []OwnershipShare, deceasedOwnerID string, heirs []OwnershipShare) ([]OwnershipShare, float64) {
+	deceasedShare := 0.0
+	for _, owner := range owners {
+		if owner.OwnerID == deceasedOwnerID {
+			deceasedShare = owner.Share
+		}
+	}
+	if deceasedShare == 0 {
+		return owners, 0
+	}
+
+	var inheritors []OwnershipShare
+	inherited := map[string]float64{}
+	for _, heir := range heirs {
+		inherited[heir.OwnerID] = deceasedShare * heir.Share / 100
+	}
+	for _, owner := range owners {
+		if owner.OwnerID == deceasedOwnerID {
+			continue
+		}
+		owner.Share += inherited[owner.OwnerID]
+		delete(inherited, owner.OwnerID)
+		inheritors = append(inheritors, owner)
+	}
+	for _, heir := range heirs {
+		if share, ok := inherited[heir.OwnerID]; ok {
+			inheritors = append(inheritors, OwnershipShare{OwnerID: heir.OwnerID, OwnerName: heir.OwnerName, Share: share})
+		}
+	}
+	return inheritors, deceasedShare
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInheritShare(t *testing.T) {
+	alice := OwnershipShare{OwnerID: "Org1MSP::alice", OwnerName: "Alice", Share: 50}
+	bob := OwnershipShare{OwnerID: "Org1MSP::bob", OwnerName: "Bob", Share: 30}
+	carol := OwnershipShare{OwnerID: "Org2MSP::carol", OwnerName: "Carol", Share: 20}
+
+	tests := []struct {
+		name          string
+		owners        []OwnershipShare
+		heirs         []OwnershipShare
+		want          map[string]float64
+		deceasedShare float64
+	}{
+		{
+			name:          "sole owner to one heir",
+			owners:        []OwnershipShare{{OwnerID: "Org1MSP::alice", Share: 100}},
+			heirs:         []OwnershipShare{{OwnerID: "Org1MSP::dave", Share: 100}},
+			want:          map[string]float64{"Org1MSP::dave": 100},
+			deceasedShare: 100,
+		},
+		{
+			name:          "sole owner split between heirs",
+			owners:        []OwnershipShare{{OwnerID: "Org1MSP::alice", Share: 100}},
+			heirs:         []OwnershipShare{{OwnerID: "Org1MSP::dave", Share: 50}, {OwnerID: "Org1MSP::erin", Share: 25}, {OwnerID: "Org1MSP::frank", Share: 25}},
+			want:          map[string]float64{"Org1MSP::dave": 50, "Org1MSP::erin": 25, "Org1MSP::frank": 25},
+			deceasedShare: 100,
+		},
+		{
+			name:          "co-owner's share to new heirs",
+			owners:        []OwnershipShare{alice, bob, carol},
+			heirs:         []OwnershipShare{{OwnerID: "Org1MSP::dave", Share: 60}, {OwnerID: "Org1MSP::erin", Share: 40}},
+			want:          map[string]float64{"Org1MSP::bob": 30, "Org2MSP::carol": 20, "Org1MSP::dave": 30, "Org1MSP::erin": 20},
+			deceasedShare: 50,
+		},
+		{
+			name:          "heir already a co-owner adds to their share",
+			owners:        []OwnershipShare{alice, bob, carol},
+			heirs:         []OwnershipShare{{OwnerID: "Org1MSP::bob", Share: 50}, {OwnerID: "Org1MSP::dave", Share: 50}},
+			want:          map[string]float64{"Org1MSP::bob": 55, "Org2MSP::carol": 20, "Org1MSP::dave": 25},
+			deceasedShare: 50,
+		},
+		{
+			name:          "surviving co-owner inherits everything",
+			owners:        []OwnershipShare{alice, {OwnerID: "Org1MSP::bob", Share: 50}},
+			heirs:         []OwnershipShare{{OwnerID: "Org1MSP::bob", Share: 100}},
+			want:          map[string]float64{"Org1MSP::bob": 100},
+			deceasedShare: 50,
+		},
+		{
+			name:          "unequal thirds",
+			owners:        []OwnershipShare{{OwnerID: "Org1MSP::alice", Share: 100.0 / 3}, {OwnerID: "Org1MSP::bob", Share: 200.0 / 3}},
+			heirs:         []OwnershipShare{{OwnerID: "Org1MSP::dave", Share: 100.0 / 3}, {OwnerID: "Org1MSP::erin", Share: 200.0 / 3}},
+			want:          map[string]float64{"Org1MSP::bob": 200.0 / 3, "Org1MSP::dave": 100.0 / 9, "Org1MSP::erin": 200.0 / 9},
+			deceasedShare: 100.0 / 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owners, deceasedShare := inheritShare(tt.owners, "Org1MSP::alice", tt.heirs)
+			if math.Abs(deceasedShare-tt.deceasedShare) > shareEpsilon {
+				t.Errorf("deceased share = %v, want %v", deceasedShare, tt.deceasedShare)
+			}
+			if len(owners) != len(tt.want) {
+				t.Fatalf("owners = %+v, want %v", owners, tt.want)
+			}
+			for _, owner := range owners {
+				want, ok := tt.want[owner.OwnerID]
+				if !ok || math.Abs(owner.Share-want) > shareEpsilon {
+					t.Errorf("owner %s holds %v, want %v", owner.OwnerID, owner.Share, want)
+				}
+			}
+			if err := validateOwnerShares(owners); err != nil {
+				t.Errorf("inherited shares are invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestInheritShareFromNonOwner(t *testing.T) {
+	owners := []OwnershipShare{{OwnerID: "Org1MSP::bob", Share: 100}}
+	got, deceasedShare := inheritShare(owners, "Org1MSP::alice", []OwnershipShare{{OwnerID: "Org1MSP::dave", Share: 100}})
+	if deceasedShare != 0 {
+		t.Fatalf("deceased share = %v, want 0", deceasedShare)
+	}
+	if len(got) != 1 || got[0] != owners[0] {
+		t.Fatalf("owners = %+v, want them unchanged", got)
+	}
+}
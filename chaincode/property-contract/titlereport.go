@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Title report events
+const (
+	titleEventRegistered            = "REGISTERED"
+	titleEventVerified              = "VERIFIED"
+	titleEventStatusChanged         = "STATUS_CHANGED"
+	titleEventOwnershipChanged      = "OWNERSHIP_CHANGED"
+	titleEventRetired               = "RETIRED"
+	titleEventTransfer              = "TRANSFER"
+	titleEventEncumbranceRegistered = "ENCUMBRANCE_REGISTERED"
+	titleEventEncumbranceDischarged = "ENCUMBRANCE_DISCHARGED"
+)
+
+// TitleReportEntry is one event in the title of a property or one of its ancestors
+type TitleReportEntry struct {
+	Timestamp   time.Time        `json:"timestamp"`
+	Event       string           `json:"event"`
+	PropertyID  string           `json:"propertyId"`
+	TxID        string           `json:"txId"`      // ledger transaction, empty for events read from records
+	Reference   string           `json:"reference"` // transaction or encumbrance ID
+	Party       string           `json:"party"`     // verifier, encumbrance holder or new owner
+	Amount      float64          `json:"amount"`
+	Owners      []OwnershipShare `json:"owners,omitempty" metadata:",optional"`
+	Description string           `json:"description"`
+}
+
+// TitleReport is an encumbrance certificate: every title event between two
+// dates, in order, with the owners and open encumbrances as of the end date.
+// Hash is the SHA-256 of the report's canonical JSON with GeneratedAt and Hash
+// left empty; VerifyTitleReport recomputes it from the ledger.
+type TitleReport struct {
+	PropertyID       string             `json:"propertyId"`
+	FromDate         time.Time          `json:"fromDate"`
+	ToDate           time.Time          `json:"toDate"`
+	Entries          []TitleReportEntry `json:"entries"`
+	Owners           []OwnershipShare   `json:"owners"`
+	OpenEncumbrances []string           `json:"openEncumbrances"`
+	GeneratedAt      time.Time          `json:"generatedAt"`
+	Hash             string             `json:"hash"`
+}
+
+// GetTitleReport compiles the title report of a property between two RFC 3339
+// dates from its history, its ancestors' history, the transaction records and
+// the encumbrances registered against it. An empty fromDate starts at genesis.
+func (c *PropertyContract) GetTitleReport(ctx contractapi.TransactionContextInterface, propertyID string, fromDate string, toDate string) (*TitleReport, error) {
+	var from time.Time
+	var err error
+	if fromDate != "" {
+		from, err = time.Parse(time.RFC3339, fromDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from date: %v", err)
+		}
+	}
+	to, err := time.Parse(time.RFC3339, toDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to date: %v", err)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("to date must not be before from date")
+	}
+
+	report, err := c.buildTitleReport(ctx, propertyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	report.GeneratedAt = time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)).UTC()
+
+	return report, nil
+}
+
+// VerifyTitleReport reports whether a title report is unaltered and still
+// matches the ledger for the same property and dates
+func (c *PropertyContract) VerifyTitleReport(ctx contractapi.TransactionContextInterface, reportJSON string) (bool, error) {
+	var report TitleReport
+	err := json.Unmarshal([]byte(reportJSON), &report)
+	if err != nil {
+		return false, fmt.Errorf("invalid title report: %v", err)
+	}
+
+	hash, err := hashTitleReport(&report)
+	if err != nil {
+		return false, err
+	}
+	if hash != report.Hash {
+		return false, nil
+	}
+
+	ledgerReport, err := c.buildTitleReport(ctx, report.PropertyID, report.FromDate, report.ToDate)
+	if err != nil {
+		return false, err
+	}
+
+	return ledgerReport.Hash == report.Hash, nil
+}
+
+// buildTitleReport assembles and hashes the report; all times are UTC so that
+// every peer produces the same canonical JSON
+func (c *PropertyContract) buildTitleReport(ctx contractapi.TransactionContextInterface, propertyID string, from time.Time, to time.Time) (*TitleReport, error) {
+	if _, err := c.getProperty(ctx, propertyID); err != nil {
+		return nil, err
+	}
+
+	report := &TitleReport{
+		PropertyID:       propertyID,
+		FromDate:         from.UTC(),
+		ToDate:           to.UTC(),
+		Entries:          []TitleReportEntry{},
+		Owners:           []OwnershipShare{},
+		OpenEncumbrances: []string{},
+	}
+
+	lineage, err := c.propertyLineage(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []TitleReportEntry
+	for _, id := range lineage {
+		records, err := c.propertyKeyHistory(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		historyEntries, owners := historyTitleEntries(records, report.ToDate)
+		entries = append(entries, historyEntries...)
+		if id == propertyID && owners != nil {
+			report.Owners = owners
+		}
+
+		transactions, err := transactionsForProperty(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, transaction := range transactions {
+			// Records written before co-ownership carry no share and moved the whole title
+			description := fmt.Sprintf("title transferred from %s to %s", transaction.FromOwner, transaction.ToOwner)
+			if transaction.Share > 0 && transaction.Share < 100 {
+				description = fmt.Sprintf("%.4f%% share transferred from %s to %s", transaction.Share, transaction.FromOwner, transaction.ToOwner)
+			}
+			switch transaction.TransferType {
+			case transferGift:
+				description += " as a gift"
+			case transferCourtOrder, transferGovernmentAcquisition:
+				description += fmt.Sprintf(" by %s %s", transaction.TransferType, transaction.LegalReference)
+			}
+			if transaction.Succession != nil {
+				description = fmt.Sprintf("%.4f%% share of %s passed by succession (%s %s)", transaction.Share, transaction.FromOwner, transaction.Succession.InstrumentType, transaction.Succession.AuthorityReference)
+			}
+			entries = append(entries, TitleReportEntry{
+				Timestamp:   transaction.Timestamp.UTC(),
+				Event:       titleEventTransfer,
+				PropertyID:  id,
+				Reference:   transaction.TransactionID,
+				Party:       transaction.ToOwner,
+				Amount:      transaction.Amount,
+				Description: description,
+			})
+		}
+
+		encumbrances, err := c.GetEncumbrances(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, encumbrance := range encumbrances {
+			entries = append(entries, TitleReportEntry{
+				Timestamp:   encumbrance.CreatedAt.UTC(),
+				Event:       titleEventEncumbranceRegistered,
+				PropertyID:  id,
+				Reference:   encumbrance.EncumbranceID,
+				Party:       encumbrance.HolderMSPID,
+				Amount:      encumbrance.Amount,
+				Description: fmt.Sprintf("%s from %s", encumbrance.Type, encumbrance.StartDate.UTC().Format(time.RFC3339)),
+			})
+			discharged := encumbrance.Status == encumbranceDischarged && !encumbrance.DischargedAt.UTC().After(report.ToDate)
+			if discharged {
+				entries = append(entries, TitleReportEntry{
+					Timestamp:   encumbrance.DischargedAt.UTC(),
+					Event:       titleEventEncumbranceDischarged,
+					PropertyID:  id,
+					Reference:   encumbrance.EncumbranceID,
+					Party:       encumbrance.HolderMSPID,
+					Description: fmt.Sprintf("%s discharged by %s", encumbrance.Type, encumbrance.DischargedBy),
+				})
+			}
+			if !discharged && !encumbrance.CreatedAt.UTC().After(report.ToDate) {
+				report.OpenEncumbrances = append(report.OpenEncumbrances, encumbrance.EncumbranceID)
+			}
+		}
+	}
+
+	for _, entry := range entries {
+		if !entry.Timestamp.Before(report.FromDate) && !entry.Timestamp.After(report.ToDate) {
+			report.Entries = append(report.Entries, entry)
+		}
+	}
+	sort.Slice(report.Entries, func(i, j int) bool {
+		a, b := report.Entries[i], report.Entries[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.Before(b.Timestamp)
+		}
+		if a.PropertyID != b.PropertyID {
+			return a.PropertyID < b.PropertyID
+		}
+		if a.Event != b.Event {
+			return a.Event < b.Event
+		}
+		return a.Reference < b.Reference
+	})
+	sort.Strings(report.OpenEncumbrances)
+
+	report.Hash, err = hashTitleReport(report)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// historyTitleEntries turns the history of one property, newest first, into
+// title events and returns its owners as of the given time
+func historyTitleEntries(records []propertyRecord, asOf time.Time) ([]TitleReportEntry, []OwnershipShare) {
+	var entries []TitleReportEntry
+	var owners []OwnershipShare
+	var previous *Property
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.Modification.IsDelete {
+			continue
+		}
+		current := record.Property
+		timestamp := time.Unix(record.Modification.Timestamp.Seconds, int64(record.Modification.Timestamp.Nanos)).UTC()
+		entry := TitleReportEntry{
+			Timestamp:  timestamp,
+			PropertyID: current.PropertyID,
+			TxID:       record.Modification.TxId,
+		}
+
+		switch {
+		case previous == nil:
+			entry.Event = titleEventRegistered
+			entry.Owners = current.ownerShares()
+			entry.Description = fmt.Sprintf("registered with status %s", current.Status)
+			if len(current.ParentIDs) > 0 {
+				entry.Description = fmt.Sprintf("created from %s", strings.Join(current.ParentIDs, ", "))
+			}
+			entries = append(entries, entry)
+		case current.Status != previous.Status:
+			entry.Event = titleEventStatusChanged
+			entry.Description = fmt.Sprintf("%s to %s", previous.Status, current.Status)
+			if current.Status == statusVerified && current.VerifiedBy != previous.VerifiedBy {
+				entry.Event = titleEventVerified
+				entry.Party = current.VerifiedBy
+			}
+			if current.Status == statusRetired {
+				entry.Event = titleEventRetired
+				entry.Description = fmt.Sprintf("replaced by %s", strings.Join(current.ChildIDs, ", "))
+			}
+			entries = append(entries, entry)
+		}
+
+		if previous != nil && !sameOwnership(current.ownerShares(), previous.ownerShares()) {
+			entries = append(entries, TitleReportEntry{
+				Timestamp:   timestamp,
+				Event:       titleEventOwnershipChanged,
+				PropertyID:  current.PropertyID,
+				TxID:        record.Modification.TxId,
+				Owners:      current.ownerShares(),
+				Description: fmt.Sprintf("owner of record %s", current.Owner),
+			})
+		}
+
+		if !timestamp.After(asOf) {
+			owners = current.ownerShares()
+		}
+		previous = &current
+	}
+
+	return entries, owners
+}
+
+// hashTitleReport returns the hex SHA-256 of a report's canonical JSON,
+// excluding GeneratedAt and Hash
+func hashTitleReport(report *TitleReport) (string, error) {
+	canonical := *report
+	canonical.GeneratedAt = time.Time{}
+	canonical.Hash = ""
+
+	reportJSON, err := json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(reportJSON)
+	return hex.EncodeToString(sum[:]), nil
+}
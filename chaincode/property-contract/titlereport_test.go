@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// sampleTitleReport returns a report as buildTitleReport produces it, all times UTC
+func sampleTitleReport() *TitleReport {
+	registered := time.Date(2023, 6, 1, 9, 30, 0, 0, time.UTC)
+	sold := time.Date(2024, 2, 15, 14, 5, 30, 250000000, time.UTC)
+	return &TitleReport{
+		PropertyID: "PROP_001",
+		FromDate:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		ToDate:     time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		Entries: []TitleReportEntry{
+			{
+				Timestamp:   registered,
+				Event:       titleEventRegistered,
+				PropertyID:  "PROP_001",
+				TxID:        "tx1",
+				Owners:      []OwnershipShare{{OwnerID: "Org1MSP::alice", OwnerName: "Alice", Share: 100}},
+				Description: "registered with status PENDING",
+			},
+			{
+				Timestamp:  sold,
+				Event:      titleEventTransfer,
+				PropertyID: "PROP_001",
+				Reference:  "TXN_1",
+				Party:      "Org2MSP::bob",
+				Amount:     4500000,
+			},
+		},
+		Owners:           []OwnershipShare{{OwnerID: "Org2MSP::bob", OwnerName: "Bob", Share: 100}},
+		OpenEncumbrances: []string{},
+	}
+}
+
+// sampleTitleReportHash pins the canonical form: reports issued earlier must
+// keep verifying, so a change here means the JSON layout of the report changed
+const sampleTitleReportHash = "e58502ff7127a64ab6684b93aff4dccfa144ba2993bf1bb5ff71ea5129287c9a"
+
+func TestHashTitleReportIsStable(t *testing.T) {
+	hash, err := hashTitleReport(sampleTitleReport())
+	if err != nil {
+		t.Fatalf("hashTitleReport: %v", err)
+	}
+	if hash != sampleTitleReportHash {
+		t.Fatalf("hash = %s, want %s", hash, sampleTitleReportHash)
+	}
+
+	again, err := hashTitleReport(sampleTitleReport())
+	if err != nil {
+		t.Fatalf("hashTitleReport: %v", err)
+	}
+	if again != hash {
+		t.Fatalf("hash of an identical report = %s, want %s", again, hash)
+	}
+}
+
+func TestHashTitleReportIgnoresGeneratedAtAndHash(t *testing.T) {
+	want, err := hashTitleReport(sampleTitleReport())
+	if err != nil {
+		t.Fatalf("hashTitleReport: %v", err)
+	}
+
+	report := sampleTitleReport()
+	report.GeneratedAt = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	report.Hash = want
+	if got, err := hashTitleReport(report); err != nil || got != want {
+		t.Fatalf("hashTitleReport = %s, %v, want %s", got, err, want)
+	}
+	if report.Hash != want || report.GeneratedAt.IsZero() {
+		t.Fatalf("hashTitleReport modified the report")
+	}
+}
+
+func TestHashTitleReportSurvivesJSONRoundTrip(t *testing.T) {
+	report := sampleTitleReport()
+	hash, err := hashTitleReport(report)
+	if err != nil {
+		t.Fatalf("hashTitleReport: %v", err)
+	}
+	report.Hash = hash
+	report.GeneratedAt = time.Now().UTC()
+
+	// VerifyTitleReport hashes the report it is handed back
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded TitleReport
+	if err := json.Unmarshal(reportJSON, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if got, err := hashTitleReport(&decoded); err != nil || got != decoded.Hash {
+		t.Fatalf("hash after round trip = %s, %v, want %s", got, err, decoded.Hash)
+	}
+}
+
+func TestHashTitleReportDetectsChanges(t *testing.T) {
+	want, err := hashTitleReport(sampleTitleReport())
+	if err != nil {
+		t.Fatalf("hashTitleReport: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(report *TitleReport)
+	}{
+		{"amount", func(report *TitleReport) { report.Entries[1].Amount = 450000 }},
+		{"buyer", func(report *TitleReport) { report.Entries[1].Party = "Org2MSP::mallory" }},
+		{"owner share", func(report *TitleReport) { report.Owners[0].Share = 99 }},
+		{"dropped entry", func(report *TitleReport) { report.Entries = report.Entries[:1] }},
+		{"reordered entries", func(report *TitleReport) {
+			report.Entries[0], report.Entries[1] = report.Entries[1], report.Entries[0]
+		}},
+		{"hidden encumbrance", func(report *TitleReport) { report.OpenEncumbrances = []string{"ENC_1"} }},
+		{"end date", func(report *TitleReport) { report.ToDate = report.ToDate.Add(time.Second) }},
+		{"event time zone", func(report *TitleReport) {
+			report.Entries[0].Timestamp = report.Entries[0].Timestamp.In(time.FixedZone("IST", 5*3600+1800))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := sampleTitleReport()
+			tt.tamper(report)
+			got, err := hashTitleReport(report)
+			if err != nil {
+				t.Fatalf("hashTitleReport: %v", err)
+			}
+			if got == want {
+				t.Fatalf("hash did not change when the %s changed", tt.name)
+			}
+		})
+	}
+}
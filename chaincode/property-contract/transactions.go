@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"couchquery"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// dateRangeSort orders a date range query by recorded time using the
+// docType and recordedAt index
+var dateRangeSort = []map[string]string{{"docType": "asc"}, {"recordedAt": "asc"}}
+
+// PaginatedTransactionResult is one page of transactions plus the bookmark for the next page
+type PaginatedTransactionResult struct {
+	Records      []*Transaction `json:"records"`
+	Bookmark     string         `json:"bookmark"`
+	FetchedCount int32          `json:"fetchedCount"`
+}
+
+// GetTransaction returns a transaction record, including those still under legacy TXN_ keys
+func (c *PropertyContract) GetTransaction(ctx contractapi.TransactionContextInterface, transactionID string) (*Transaction, error) {
+	key, err := transactionKey(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	transactionJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transaction: %v", err)
+	}
+	if transactionJSON == nil {
+		transactionJSON, err = ctx.GetStub().GetState(legacyTransactionPrefix + transactionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read transaction: %v", err)
+		}
+	}
+	if transactionJSON == nil {
+		return nil, fmt.Errorf("transaction %s does not exist", transactionID)
+	}
+
+	var transaction Transaction
+	err = json.Unmarshal(transactionJSON, &transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// GetTransactionsByProperty returns the transactions of a property, oldest first
+func (c *PropertyContract) GetTransactionsByProperty(ctx contractapi.TransactionContextInterface, propertyID string) ([]*Transaction, error) {
+	transactions, err := transactionsForProperty(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	sortTransactions(transactions)
+	return transactions, nil
+}
+
+// GetTransactionsByParty returns the transactions a user took part in as a
+// seller, buyer or heir, oldest first
+func (c *PropertyContract) GetTransactionsByParty(ctx contractapi.TransactionContextInterface, partyID string) ([]*Transaction, error) {
+	queryString, err := couchquery.Build(partySelector(partyID))
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := queryTransactions(ctx, queryString)
+	if err != nil {
+		return nil, err
+	}
+	sortTransactions(transactions)
+	return transactions, nil
+}
+
+// GetTransactionsByDateRange returns the transactions recorded between two
+// RFC 3339 dates, inclusive, oldest first
+func (c *PropertyContract) GetTransactionsByDateRange(ctx contractapi.TransactionContextInterface, fromDate string, toDate string) ([]*Transaction, error) {
+	from, to, err := parseDateRange(fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+
+	queryString, err := couchquery.BuildSorted(dateRangeSelector(from, to), dateRangeSort)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryTransactions(ctx, queryString)
+}
+
+// GetTransactionsByPropertyWithPagination retrieves one page of a property's transactions
+func (c *PropertyContract) GetTransactionsByPropertyWithPagination(ctx contractapi.TransactionContextInterface, propertyID string, pageSize int32, bookmark string) (*PaginatedTransactionResult, error) {
+	queryString, err := couchquery.Build(transactionSelector(map[string]interface{}{"propertyId": propertyID}))
+	if err != nil {
+		return nil, err
+	}
+	return queryTransactionsWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// GetTransactionsByPartyWithPagination retrieves one page of a user's transactions
+func (c *PropertyContract) GetTransactionsByPartyWithPagination(ctx contractapi.TransactionContextInterface, partyID string, pageSize int32, bookmark string) (*PaginatedTransactionResult, error) {
+	queryString, err := couchquery.Build(partySelector(partyID))
+	if err != nil {
+		return nil, err
+	}
+	return queryTransactionsWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// GetTransactionsByDateRangeWithPagination retrieves one page of the
+// transactions recorded between two RFC 3339 dates, oldest first
+func (c *PropertyContract) GetTransactionsByDateRangeWithPagination(ctx contractapi.TransactionContextInterface, fromDate string, toDate string, pageSize int32, bookmark string) (*PaginatedTransactionResult, error) {
+	from, to, err := parseDateRange(fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+
+	queryString, err := couchquery.BuildSorted(dateRangeSelector(from, to), dateRangeSort)
+	if err != nil {
+		return nil, err
+	}
+	return queryTransactionsWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// partySelector matches transactions a user sold, bought or inherited under
+func partySelector(partyID string) map[string]interface{} {
+	return transactionSelector(map[string]interface{}{
+		"$or": []interface{}{
+			map[string]interface{}{"fromOwner": partyID},
+			map[string]interface{}{"toOwner": partyID},
+			map[string]interface{}{"fromOwners": map[string]interface{}{"$elemMatch": map[string]interface{}{"$eq": partyID}}},
+			map[string]interface{}{"succession.heirs": map[string]interface{}{
+				"$elemMatch": map[string]interface{}{"ownerId": partyID},
+			}},
+		},
+	})
+}
+
+// dateRangeSelector matches transactions recorded within an inclusive date
+// range, comparing bounds in the format recordedAt is stored in
+func dateRangeSelector(from time.Time, to time.Time) map[string]interface{} {
+	return transactionSelector(map[string]interface{}{
+		"recordedAt": map[string]interface{}{
+			"$gte": sortableTimestamp(from),
+			"$lte": sortableTimestamp(to),
+		},
+	})
+}
+
+// parseDateRange parses an inclusive RFC 3339 date range
+func parseDateRange(fromDate string, toDate string) (time.Time, time.Time, error) {
+	from, err := time.Parse(time.RFC3339, fromDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from date: %v", err)
+	}
+	to, err := time.Parse(time.RFC3339, toDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to date: %v", err)
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to date must not be before from date")
+	}
+	return from, to, nil
+}
+
+// sortTransactions orders transactions oldest first, breaking ties by ID
+func sortTransactions(transactions []*Transaction) {
+	sort.Slice(transactions, func(i, j int) bool {
+		if !transactions[i].Timestamp.Equal(transactions[j].Timestamp) {
+			return transactions[i].Timestamp.Before(transactions[j].Timestamp)
+		}
+		return transactions[i].TransactionID < transactions[j].TransactionID
+	})
+}
+
+// isTransactionKey reports whether a ledger key holds a transaction record,
+// namespaced or under a legacy TXN_ key
+func isTransactionKey(ctx contractapi.TransactionContextInterface, key string) bool {
+	return hasObjectType(ctx, key, transactionObjectType) || strings.HasPrefix(key, legacyTransactionPrefix)
+}
+
+// queryTransactions runs a rich query and returns the transaction records it matched
+func queryTransactions(ctx contractapi.TransactionContextInterface, queryString string) ([]*Transaction, error) {
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return constructTransactions(ctx, resultsIterator)
+}
+
+// queryTransactionsWithPagination runs a rich query and returns one page of transactions
+func queryTransactionsWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int32, bookmark string) (*PaginatedTransactionResult, error) {
+	if err := couchquery.ValidatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return constructTransactionPage(ctx, resultsIterator, metadata)
+}
+
+// constructTransactions reads transaction records, skipping other entity types
+// that a rich query may have matched
+func constructTransactions(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface) ([]*Transaction, error) {
+	transactions := []*Transaction{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !isTransactionKey(ctx, queryResponse.Key) {
+			continue
+		}
+
+		var transaction Transaction
+		err = json.Unmarshal(queryResponse.Value, &transaction)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, &transaction)
+	}
+
+	return transactions, nil
+}
+
+// constructTransactionPage reads a page of transaction records
+func constructTransactionPage(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface, metadata *peer.QueryResponseMetadata) (*PaginatedTransactionResult, error) {
+	transactions, err := constructTransactions(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedTransactionResult{
+		Records:      transactions,
+		Bookmark:     metadata.Bookmark,
+		FetchedCount: metadata.FetchedRecordsCount,
+	}, nil
+}
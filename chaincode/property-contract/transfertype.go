@@ -0,0 +1,58 @@
+package main
+
+import "fmt"
+
+// Transfer types. Records written before transfer types were introduced have
+// none and were sales.
+const (
+	transferSale                  = "SALE"
+	transferGift                  = "GIFT"
+	transferSuccession            = "SUCCESSION"
+	transferCourtOrder            = "COURT_ORDER"
+	transferGovernmentAcquisition = "GOVERNMENT_ACQUISITION"
+)
+
+// transferTerms are the terms a title or share changes hands under
+type transferTerms struct {
+	TransferType   string
+	Consideration  float64
+	EscrowID       string
+	FeePaymentRef  string
+	LegalReference string
+}
+
+// isVoluntaryTransfer reports whether a transfer type is made by the owners,
+// who must all consent, rather than imposed by a court or the state
+func isVoluntaryTransfer(transferType string) bool {
+	return transferType == transferSale || transferType == transferGift
+}
+
+// validateTransferTerms checks the consideration and legal reference a transfer
+// type requires: a sale is paid for, a gift is not, and transfers imposed by a
+// court or the state cite the order or notification behind them
+func validateTransferTerms(terms *transferTerms) error {
+	if terms.Consideration < 0 {
+		return fmt.Errorf("consideration cannot be negative")
+	}
+
+	switch terms.TransferType {
+	case transferSale:
+		if terms.Consideration == 0 {
+			return fmt.Errorf("a sale requires the consideration paid; record unpaid transfers as %s", transferGift)
+		}
+	case transferGift:
+		if terms.Consideration != 0 {
+			return fmt.Errorf("a gift cannot carry consideration; record paid transfers as %s", transferSale)
+		}
+	case transferCourtOrder, transferGovernmentAcquisition:
+		if terms.LegalReference == "" {
+			return fmt.Errorf("a %s transfer requires the reference of the order or notification", terms.TransferType)
+		}
+	case transferSuccession:
+		return fmt.Errorf("succession transfers must be recorded with SuccessionTransfer")
+	default:
+		return fmt.Errorf("unknown transfer type %q", terms.TransferType)
+	}
+
+	return nil
+}
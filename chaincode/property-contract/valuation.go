@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"access"
+	"couchquery"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// guidelineObjectType keys guideline rates by locality, property type and
+// effective date so a partial key scan returns a rate's revisions in order
+const guidelineObjectType = "guideline"
+
+// GuidelineRate is the minimum value per area unit an authority sets for one
+// property type in one locality (a circle rate), from its effective date until
+// the next revision
+type GuidelineRate struct {
+	Locality      string    `json:"locality"`
+	PropertyType  string    `json:"propertyType"`
+	RatePerArea   float64   `json:"ratePerArea"`
+	EffectiveFrom time.Time `json:"effectiveFrom"`
+	PublishedBy   string    `json:"publishedBy"`
+	PublishedAt   time.Time `json:"publishedAt"`
+}
+
+// GuidelineValuation is a property's guideline value under the rate in force
+type GuidelineValuation struct {
+	PropertyID     string    `json:"propertyId"`
+	Locality       string    `json:"locality"`
+	PropertyType   string    `json:"propertyType"`
+	Area           float64   `json:"area"`
+	RatePerArea    float64   `json:"ratePerArea"`
+	EffectiveFrom  time.Time `json:"effectiveFrom"`
+	GuidelineValue float64   `json:"guidelineValue"`
+}
+
+// GuidelineCheck compares an amount with the guideline value of the share of a
+// property it pays for. Declaring less than the guideline value is a common
+// sign of understated consideration.
+type GuidelineCheck struct {
+	PropertyID     string  `json:"propertyId"`
+	Amount         float64 `json:"amount"`
+	HasGuideline   bool    `json:"hasGuideline"`
+	GuidelineValue float64 `json:"guidelineValue"`
+	BelowGuideline bool    `json:"belowGuideline"`
+}
+
+// guidelineKey returns the ledger key of a guideline rate revision
+func guidelineKey(ctx contractapi.TransactionContextInterface, locality string, propertyType string, effectiveFrom time.Time) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(guidelineObjectType, []string{normalizeLocation(locality), propertyType, sortableTimestamp(effectiveFrom)})
+}
+
+// SetGuidelineRate publishes the guideline rate per area unit for a property
+// type in a locality, effective from an RFC 3339 date. Earlier revisions stay
+// in force for dates before it. Registrar admin only.
+func (c *PropertyContract) SetGuidelineRate(ctx contractapi.TransactionContextInterface, locality string, propertyType string, ratePerArea float64, effectiveFrom string) error {
+	caller, err := access.RequireRegistrarAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if normalizeLocation(locality) == "" || propertyType == "" {
+		return fmt.Errorf("locality and property type are required")
+	}
+	if ratePerArea <= 0 {
+		return fmt.Errorf("guideline rate must be positive")
+	}
+	effective, err := time.Parse(time.RFC3339, effectiveFrom)
+	if err != nil {
+		return fmt.Errorf("invalid effective date: %v", err)
+	}
+
+	key, err := guidelineKey(ctx, locality, propertyType, effective)
+	if err != nil {
+		return err
+	}
+	existingJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read guideline rate: %v", err)
+	}
+	if existingJSON != nil {
+		return fmt.Errorf("a guideline rate for %s properties in %s is already effective from %s", propertyType, locality, effectiveFrom)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	rate := &GuidelineRate{
+		Locality:      normalizeLocation(locality),
+		PropertyType:  propertyType,
+		RatePerArea:   ratePerArea,
+		EffectiveFrom: effective.UTC(),
+		PublishedBy:   caller.ID,
+		PublishedAt:   time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)),
+	}
+
+	rateJSON, err := json.Marshal(rate)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, rateJSON)
+}
+
+// GetGuidelineRates returns every revision of the guideline rate for a
+// property type in a locality, oldest first
+func (c *PropertyContract) GetGuidelineRates(ctx contractapi.TransactionContextInterface, locality string, propertyType string) ([]*GuidelineRate, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(guidelineObjectType, []string{normalizeLocation(locality), propertyType})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	rates := []*GuidelineRate{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var rate GuidelineRate
+		err = json.Unmarshal(queryResponse.Value, &rate)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, &rate)
+	}
+
+	return rates, nil
+}
+
+// GetGuidelineValue returns a property's guideline value: its area times the
+// guideline rate in force for its type and locality
+func (c *PropertyContract) GetGuidelineValue(ctx contractapi.TransactionContextInterface, propertyID string) (*GuidelineValuation, error) {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+
+	valuation, err := c.guidelineValuation(ctx, property)
+	if err != nil {
+		return nil, err
+	}
+	if valuation == nil {
+		return nil, fmt.Errorf("no guideline rate is in force for %s properties in %s", property.PropertyType, property.Location)
+	}
+
+	return valuation, nil
+}
+
+// CheckGuidelineValue reports whether an amount offered or paid for a whole
+// property is below its guideline value
+func (c *PropertyContract) CheckGuidelineValue(ctx contractapi.TransactionContextInterface, propertyID string, amount float64) (*GuidelineCheck, error) {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	return c.checkGuideline(ctx, property, amount, 100)
+}
+
+// checkGuideline compares an amount paid for share percent of a property with
+// the guideline value of that share
+func (c *PropertyContract) checkGuideline(ctx contractapi.TransactionContextInterface, property *Property, amount float64, share float64) (*GuidelineCheck, error) {
+	check := &GuidelineCheck{
+		PropertyID: property.PropertyID,
+		Amount:     amount,
+	}
+
+	valuation, err := c.guidelineValuation(ctx, property)
+	if err != nil {
+		return nil, err
+	}
+	if valuation == nil {
+		return check, nil
+	}
+
+	check.HasGuideline = true
+	check.GuidelineValue = roundCurrency(valuation.GuidelineValue * share / 100)
+	check.BelowGuideline = amount < check.GuidelineValue
+
+	return check, nil
+}
+
+// guidelineValuation values a property at the guideline rate in force at the
+// transaction time, or returns nil if no rate applies
+func (c *PropertyContract) guidelineValuation(ctx contractapi.TransactionContextInterface, property *Property) (*GuidelineValuation, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	now := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	rates, err := c.GetGuidelineRates(ctx, property.Location, property.PropertyType)
+	if err != nil {
+		return nil, err
+	}
+
+	inForce := rateInForce(rates, now)
+	if inForce == nil {
+		return nil, nil
+	}
+
+	return &GuidelineValuation{
+		PropertyID:     property.PropertyID,
+		Locality:       inForce.Locality,
+		PropertyType:   property.PropertyType,
+		Area:           property.Area,
+		RatePerArea:    inForce.RatePerArea,
+		EffectiveFrom:  inForce.EffectiveFrom,
+		GuidelineValue: roundCurrency(property.Area * inForce.RatePerArea),
+	}, nil
+}
+
+// rateInForce returns the revision in effect at a time, or nil if none is yet.
+// Revisions are in effective date order; the last one already in effect applies.
+func rateInForce(rates []*GuidelineRate, at time.Time) *GuidelineRate {
+	var inForce *GuidelineRate
+	for _, rate := range rates {
+		if rate.EffectiveFrom.After(at) {
+			break
+		}
+		inForce = rate
+	}
+	return inForce
+}
+
+// GetBelowGuidelineTransactions returns the transfers recorded for less than
+// the guideline value, for review by the registrar
+func (c *PropertyContract) GetBelowGuidelineTransactions(ctx contractapi.TransactionContextInterface) ([]*Transaction, error) {
+	queryString, err := couchquery.Build(transactionSelector(map[string]interface{}{"belowGuideline": true}))
+	if err != nil {
+		return nil, err
+	}
+
+	return queryTransactions(ctx, queryString)
+}
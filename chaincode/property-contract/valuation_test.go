@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateInForce(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	rates := []*GuidelineRate{
+		{RatePerArea: 4000, EffectiveFrom: time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{RatePerArea: 4500, EffectiveFrom: time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{RatePerArea: 5200, EffectiveFrom: time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want float64 // zero when no revision is in force
+	}{
+		{"before the first revision", time.Date(2022, 3, 31, 23, 59, 59, 0, time.UTC), 0},
+		{"on the first effective date", time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC), 4000},
+		{"between revisions", time.Date(2023, 1, 15, 12, 0, 0, 0, time.UTC), 4000},
+		{"on a later effective date", time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC), 4500},
+		{"just before the latest revision", time.Date(2024, 9, 30, 23, 59, 59, 999999999, time.UTC), 4500},
+		{"local time already past the effective instant", time.Date(2024, 10, 1, 5, 30, 0, 0, ist), 5200},
+		{"local date matching but instant before", time.Date(2024, 10, 1, 5, 29, 59, 0, ist), 4500},
+		{"after the latest revision", time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), 5200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate := rateInForce(rates, tt.at)
+			switch {
+			case tt.want == 0 && rate != nil:
+				t.Fatalf("rateInForce = %+v, want none", rate)
+			case tt.want != 0 && (rate == nil || rate.RatePerArea != tt.want):
+				t.Fatalf("rateInForce = %+v, want the %v revision", rate, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateInForceWithoutRevisions(t *testing.T) {
+	if rate := rateInForce(nil, time.Now()); rate != nil {
+		t.Fatalf("rateInForce = %+v, want none", rate)
+	}
+}
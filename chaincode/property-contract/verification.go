@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// policyObjectType namespaces contract-wide configuration records
+const policyObjectType = "policy"
+
+// verificationPolicyName is the key attribute of the verification policy
+const verificationPolicyName = "verification"
+
+// Review actions
+const (
+	reviewApprove        = "APPROVE"
+	reviewRequestChanges = "REQUEST_CHANGES"
+	reviewReject         = "REJECT"
+	reviewResubmit       = "RESUBMIT"
+	reviewComment        = "COMMENT"
+)
+
+// VerificationPolicy is the number of approvals a registration needs before it
+// becomes VERIFIED and which organizations' verifiers may give them
+type VerificationPolicy struct {
+	RequiredApprovals   int      `json:"requiredApprovals"`
+	RequireDistinctOrgs bool     `json:"requireDistinctOrgs"`
+	VerifierMSPIDs      []string `json:"verifierMspIds"`
+}
+
+// ReviewEntry is one step of a property's verification review
+type ReviewEntry struct {
+	ReviewerID    string    `json:"reviewerId"`
+	ReviewerMSPID string    `json:"reviewerMspId"`
+	Action        string    `json:"action"` // APPROVE, REQUEST_CHANGES, REJECT, RESUBMIT, COMMENT
+	Comment       string    `json:"comment"`
+	TxID          string    `json:"txId"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// Approval is a verifier's sign-off in the current review round
+type Approval struct {
+	VerifierID    string    `json:"verifierId"`
+	VerifierMSPID string    `json:"verifierMspId"`
+	ApprovedAt    time.Time `json:"approvedAt"`
+}
+
+// defaultVerificationPolicy applies until SetVerificationPolicy is called: a
+// single approval from the registrar org
+func defaultVerificationPolicy() *VerificationPolicy {
+	return &VerificationPolicy{
+		RequiredApprovals:   1,
+		RequireDistinctOrgs: false,
+		VerifierMSPIDs:      []string{access.RegistrarMSPID},
+	}
+}
+
+// allowsMSP reports whether verifiers of an organization may review properties
+func (p *VerificationPolicy) allowsMSP(mspID string) bool {
+	for _, allowed := range p.VerifierMSPIDs {
+		if allowed == mspID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetVerificationPolicy returns the approval rule for new verifications
+func (c *PropertyContract) GetVerificationPolicy(ctx contractapi.TransactionContextInterface) (*VerificationPolicy, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(policyObjectType, []string{verificationPolicyName})
+	if err != nil {
+		return nil, err
+	}
+
+	policyJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read verification policy: %v", err)
+	}
+	if policyJSON == nil {
+		return defaultVerificationPolicy(), nil
+	}
+
+	var policy VerificationPolicy
+	err = json.Unmarshal(policyJSON, &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// SetVerificationPolicy sets how many distinct verifiers, optionally from
+// distinct organizations, must approve a registration, e.g. a two-person rule.
+// Approvals already given count towards the new rule. Registrar admin only.
+func (c *PropertyContract) SetVerificationPolicy(ctx contractapi.TransactionContextInterface, requiredApprovals int, requireDistinctOrgs bool, verifierMSPIDsJSON string) error {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return err
+	}
+
+	var verifierMSPIDs []string
+	err := json.Unmarshal([]byte(verifierMSPIDsJSON), &verifierMSPIDs)
+	if err != nil {
+		return fmt.Errorf("invalid verifier MSP IDs: %v", err)
+	}
+	if len(verifierMSPIDs) == 0 {
+		return fmt.Errorf("at least one verifier MSP ID is required")
+	}
+	if requiredApprovals < 1 {
+		return fmt.Errorf("required approvals must be at least 1")
+	}
+	if requireDistinctOrgs && requiredApprovals > len(verifierMSPIDs) {
+		return fmt.Errorf("%d approvals from distinct organizations cannot be met by %d verifier organizations", requiredApprovals, len(verifierMSPIDs))
+	}
+
+	policy := &VerificationPolicy{
+		RequiredApprovals:   requiredApprovals,
+		RequireDistinctOrgs: requireDistinctOrgs,
+		VerifierMSPIDs:      verifierMSPIDs,
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(policyObjectType, []string{verificationPolicyName})
+	if err != nil {
+		return err
+	}
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, policyJSON)
+}
+
+// requireVerifier returns the caller if it is a verifier or admin of an
+// organization the policy accepts and does not own the property
+func (c *PropertyContract) requireVerifier(ctx contractapi.TransactionContextInterface, property *Property) (*access.Caller, *VerificationPolicy, error) {
+	caller, err := access.GetCaller(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	policy, err := c.GetVerificationPolicy(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !caller.HasRole(access.RoleVerifier, access.RoleAdmin) || !policy.allowsMSP(caller.MSPID) {
+		return nil, nil, fmt.Errorf("access denied: caller %s must be a VERIFIER or ADMIN of %v", caller.ID, policy.VerifierMSPIDs)
+	}
+	if property.isOwner(caller.ID) {
+		return nil, nil, fmt.Errorf("access denied: caller %s cannot review its own property %s", caller.ID, property.PropertyID)
+	}
+
+	return caller, policy, nil
+}
+
+// VerifyProperty records the caller's approval of a pending registration. The
+// property becomes VERIFIED once the approvals satisfy the verification policy.
+func (c *PropertyContract) VerifyProperty(ctx contractapi.TransactionContextInterface, propertyID string, comment string) error {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	caller, policy, err := c.requireVerifier(ctx, property)
+	if err != nil {
+		return err
+	}
+
+	if err := validateTransition(propertyID, property.Status, statusVerified); err != nil {
+		return err
+	}
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+
+	for _, approval := range property.Approvals {
+		if approval.VerifierID == caller.ID {
+			return fmt.Errorf("caller %s has already approved property %s", caller.ID, propertyID)
+		}
+		if policy.RequireDistinctOrgs && approval.VerifierMSPID == caller.MSPID {
+			return fmt.Errorf("property %s has already been approved by a verifier of %s", propertyID, caller.MSPID)
+		}
+	}
+
+	timestamp, err := c.addReview(ctx, property, caller, reviewApprove, comment)
+	if err != nil {
+		return err
+	}
+
+	property.Approvals = append(property.Approvals, Approval{
+		VerifierID:    caller.ID,
+		VerifierMSPID: caller.MSPID,
+		ApprovedAt:    timestamp,
+	})
+
+	if len(property.Approvals) >= policy.RequiredApprovals {
+		property.Status = statusVerified
+		property.VerifiedBy = caller.ID
+		property.VerifiedAt = timestamp
+	}
+
+	return c.putProperty(ctx, property)
+}
+
+// RequestChanges sends a pending registration back to its owner, e.g. for
+// missing documents, discarding the approvals given so far
+func (c *PropertyContract) RequestChanges(ctx contractapi.TransactionContextInterface, propertyID string, comment string) error {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	caller, _, err := c.requireVerifier(ctx, property)
+	if err != nil {
+		return err
+	}
+
+	if comment == "" {
+		return fmt.Errorf("a comment explaining the requested changes is required")
+	}
+	if err := validateTransition(propertyID, property.Status, statusChangesRequested); err != nil {
+		return err
+	}
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+
+	if _, err := c.addReview(ctx, property, caller, reviewRequestChanges, comment); err != nil {
+		return err
+	}
+
+	property.Status = statusChangesRequested
+	property.Approvals = nil
+
+	return c.putProperty(ctx, property)
+}
+
+// RejectProperty turns down a registration with a reason
+func (c *PropertyContract) RejectProperty(ctx contractapi.TransactionContextInterface, propertyID string, reason string) error {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	caller, _, err := c.requireVerifier(ctx, property)
+	if err != nil {
+		return err
+	}
+
+	if reason == "" {
+		return fmt.Errorf("a rejection reason is required")
+	}
+	if err := validateTransition(propertyID, property.Status, statusRejected); err != nil {
+		return err
+	}
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+
+	if _, err := c.addReview(ctx, property, caller, reviewReject, reason); err != nil {
+		return err
+	}
+
+	property.Status = statusRejected
+	property.RejectionReason = reason
+	property.Approvals = nil
+
+	return c.putProperty(ctx, property)
+}
+
+// ResubmitProperty returns a registration sent back for changes, or rejected,
+// to review; only an owner may resubmit
+func (c *PropertyContract) ResubmitProperty(ctx contractapi.TransactionContextInterface, propertyID string, comment string) error {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	caller, err := requireOwner(ctx, property)
+	if err != nil {
+		return err
+	}
+
+	if err := validateTransition(propertyID, property.Status, statusPendingVerification); err != nil {
+		return err
+	}
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+
+	if _, err := c.addReview(ctx, property, caller, reviewResubmit, comment); err != nil {
+		return err
+	}
+
+	property.Status = statusPendingVerification
+	property.RejectionReason = ""
+	property.Approvals = nil
+
+	return c.putProperty(ctx, property)
+}
+
+// AddReviewComment adds a verifier's comment to a registration under review
+// without changing its status
+func (c *PropertyContract) AddReviewComment(ctx contractapi.TransactionContextInterface, propertyID string, comment string) error {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	caller, _, err := c.requireVerifier(ctx, property)
+	if err != nil {
+		return err
+	}
+
+	if comment == "" {
+		return fmt.Errorf("comment is required")
+	}
+	if property.Status != statusPendingVerification && property.Status != statusChangesRequested {
+		return fmt.Errorf("property %s is not under review", propertyID)
+	}
+
+	if _, err := c.addReview(ctx, property, caller, reviewComment, comment); err != nil {
+		return err
+	}
+
+	return c.putProperty(ctx, property)
+}
+
+// addReview appends a review entry to the property and returns the transaction time
+func (c *PropertyContract) addReview(ctx contractapi.TransactionContextInterface, property *Property, caller *access.Caller, action string, comment string) (time.Time, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	property.Reviews = append(property.Reviews, ReviewEntry{
+		ReviewerID:    caller.ID,
+		ReviewerMSPID: caller.MSPID,
+		Action:        action,
+		Comment:       comment,
+		TxID:          ctx.GetStub().GetTxID(),
+		Timestamp:     timestamp,
+	})
+	property.LastUpdated = timestamp
+
+	return timestamp, nil
+}
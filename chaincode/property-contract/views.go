@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	// viewsObjectType keys one delta per counted view: views~propertyID~txID
+	viewsObjectType = "views"
+
+	// viewCountObjectType keys the compacted view counter of a property
+	viewCountObjectType = "viewcount"
+)
+
+// IncrementPropertyViews counts a view. It writes a delta key unique to the
+// transaction and reads nothing, so concurrent views never conflict with each
+// other or with writes to the property document.
+func (c *PropertyContract) IncrementPropertyViews(ctx contractapi.TransactionContextInterface, propertyID string) error {
+	if propertyID == "" {
+		return fmt.Errorf("property ID is required")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey(viewsObjectType, []string{propertyID, ctx.GetStub().GetTxID()})
+	if err != nil {
+		return err
+	}
+
+	// Delta entries carry no data; a single byte is stored because an empty value deletes the key
+	return ctx.GetStub().PutState(key, []byte{0x01})
+}
+
+// CompactPropertyViews folds the pending view deltas of a property into its
+// counter and returns how many were folded. Views counted while it runs make
+// it fail with a phantom read and can simply be retried. Registrar admin only.
+func (c *PropertyContract) CompactPropertyViews(ctx contractapi.TransactionContextInterface, propertyID string) (int, error) {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return 0, err
+	}
+	return compactViews(ctx, []string{propertyID})
+}
+
+// CompactAllPropertyViews folds the pending view deltas of every property into
+// their counters, for a periodic maintenance job. Registrar admin only.
+func (c *PropertyContract) CompactAllPropertyViews(ctx contractapi.TransactionContextInterface) (int, error) {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return 0, err
+	}
+	return compactViews(ctx, []string{})
+}
+
+// compactViews deletes the view deltas under a partial key and adds them to
+// each property's counter
+func compactViews(ctx contractapi.TransactionContextInterface, attributes []string) (int, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(viewsObjectType, attributes)
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	pending := map[string]int{}
+	var propertyIDs []string
+	compacted := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return compacted, err
+		}
+
+		_, keyAttributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return compacted, err
+		}
+		propertyID := keyAttributes[0]
+		if _, ok := pending[propertyID]; !ok {
+			propertyIDs = append(propertyIDs, propertyID)
+		}
+		pending[propertyID]++
+
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return compacted, err
+		}
+		compacted++
+	}
+
+	for _, propertyID := range propertyIDs {
+		count, err := compactedViews(ctx, propertyID)
+		if err != nil {
+			return compacted, err
+		}
+
+		key, err := ctx.GetStub().CreateCompositeKey(viewCountObjectType, []string{propertyID})
+		if err != nil {
+			return compacted, err
+		}
+		err = ctx.GetStub().PutState(key, []byte(strconv.Itoa(count+pending[propertyID])))
+		if err != nil {
+			return compacted, err
+		}
+	}
+
+	return compacted, nil
+}
+
+// compactedViews returns the counter written by the last compaction
+func compactedViews(ctx contractapi.TransactionContextInterface, propertyID string) (int, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(viewCountObjectType, []string{propertyID})
+	if err != nil {
+		return 0, err
+	}
+
+	countBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read view count: %v", err)
+	}
+	if countBytes == nil {
+		return 0, nil
+	}
+
+	count, err := strconv.Atoi(string(countBytes))
+	if err != nil {
+		return 0, fmt.Errorf("invalid view count for property %s: %v", propertyID, err)
+	}
+	return count, nil
+}
+
+// withCompactedViews adds the compacted counter to the count stored on the
+// document before view deltas were introduced. List and page queries use it so
+// each property costs one point read rather than a scan of its deltas; their
+// counts are as of the last compaction. Only read paths may call it; a property
+// with its views filled in must never be written back.
+func withCompactedViews(ctx contractapi.TransactionContextInterface, property *Property) error {
+	count, err := compactedViews(ctx, property.PropertyID)
+	if err != nil {
+		return err
+	}
+
+	property.Views += count
+	return nil
+}
+
+// withViews sets a property's Views to its total: the compacted count plus the
+// deltas not yet compacted. Only GetProperty calls it, so the delta scan runs
+// for one property at a time.
+func withViews(ctx contractapi.TransactionContextInterface, property *Property) error {
+	if err := withCompactedViews(ctx, property); err != nil {
+		return err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(viewsObjectType, []string{property.PropertyID})
+	if err != nil {
+		return err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		if _, err := resultsIterator.Next(); err != nil {
+			return err
+		}
+		property.Views++
+	}
+
+	return nil
+}
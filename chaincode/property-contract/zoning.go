@@ -0,0 +1,415 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"access"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// zoneObjectType keys zones by zone code
+const zoneObjectType = "zone"
+
+// landUseChangeObjectType keys land-use change requests by property
+const landUseChangeObjectType = "landuse"
+
+// Property types, which are also the land uses a zone may permit
+const (
+	propertyTypeResidential  = "RESIDENTIAL"
+	propertyTypeCommercial   = "COMMERCIAL"
+	propertyTypeAgricultural = "AGRICULTURAL"
+	propertyTypeIndustrial   = "INDUSTRIAL"
+)
+
+// Land-use change request statuses
+const (
+	landUseChangePending  = "PENDING"
+	landUseChangeApproved = "APPROVED"
+	landUseChangeRejected = "REJECTED"
+)
+
+// Zone is a planning zone: the land uses it permits and the maximum floor area
+// ratio (built-up area over plot area) of buildings in it
+type Zone struct {
+	ZoneCode          string    `json:"zoneCode"`
+	Name              string    `json:"name"`
+	PermittedUses     []string  `json:"permittedUses"`
+	MaxFloorAreaRatio float64   `json:"maxFloorAreaRatio"`
+	UpdatedBy         string    `json:"updatedBy"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// LandUseChange is an owner's request to change a property's use or zone,
+// which takes effect once the planning authority approves it
+type LandUseChange struct {
+	RequestID       string    `json:"requestId"`
+	PropertyID      string    `json:"propertyId"`
+	FromType        string    `json:"fromType"`
+	FromZoneCode    string    `json:"fromZoneCode"`
+	ToType          string    `json:"toType"`
+	ToZoneCode      string    `json:"toZoneCode"`
+	Reason          string    `json:"reason"`
+	Status          string    `json:"status"` // PENDING, APPROVED, REJECTED
+	RequestedBy     string    `json:"requestedBy"`
+	RequestedAt     time.Time `json:"requestedAt"`
+	DecidedBy       string    `json:"decidedBy"`
+	DecidedAt       time.Time `json:"decidedAt"`
+	DecisionComment string    `json:"decisionComment"`
+}
+
+// isKnownPropertyType reports whether a property type is a recognised land use
+func isKnownPropertyType(propertyType string) bool {
+	switch propertyType {
+	case propertyTypeResidential, propertyTypeCommercial, propertyTypeAgricultural, propertyTypeIndustrial:
+		return true
+	}
+	return false
+}
+
+// permits reports whether a zone allows a land use
+func (z *Zone) permits(propertyType string) bool {
+	for _, use := range z.PermittedUses {
+		if use == propertyType {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneKey returns the composite ledger key of a zone
+func zoneKey(ctx contractapi.TransactionContextInterface, zoneCode string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(zoneObjectType, []string{zoneCode})
+}
+
+// landUseChangeKey returns the composite ledger key of a land-use change request
+func landUseChangeKey(ctx contractapi.TransactionContextInterface, propertyID string, requestID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(landUseChangeObjectType, []string{propertyID, requestID})
+}
+
+// SetZone creates or updates a zone of the zoning registry. permittedUsesJSON
+// lists the property types allowed in it. Properties already in the zone keep
+// their use. Registrar admin only.
+func (c *PropertyContract) SetZone(ctx contractapi.TransactionContextInterface, zoneCode string, name string, permittedUsesJSON string, maxFloorAreaRatio float64) error {
+	caller, err := access.RequireRegistrarAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if zoneCode == "" {
+		return fmt.Errorf("zone code is required")
+	}
+	if maxFloorAreaRatio <= 0 {
+		return fmt.Errorf("maximum floor area ratio must be positive")
+	}
+
+	var permittedUses []string
+	err = json.Unmarshal([]byte(permittedUsesJSON), &permittedUses)
+	if err != nil {
+		return fmt.Errorf("invalid permitted uses: %v", err)
+	}
+	if len(permittedUses) == 0 {
+		return fmt.Errorf("a zone must permit at least one land use")
+	}
+	for _, use := range permittedUses {
+		if !isKnownPropertyType(use) {
+			return fmt.Errorf("unknown land use %q", use)
+		}
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	zone := &Zone{
+		ZoneCode:          zoneCode,
+		Name:              name,
+		PermittedUses:     permittedUses,
+		MaxFloorAreaRatio: maxFloorAreaRatio,
+		UpdatedBy:         caller.ID,
+		UpdatedAt:         time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)),
+	}
+
+	key, err := zoneKey(ctx, zoneCode)
+	if err != nil {
+		return err
+	}
+
+	zoneJSON, err := json.Marshal(zone)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, zoneJSON)
+}
+
+// GetZone returns a zone of the zoning registry
+func (c *PropertyContract) GetZone(ctx contractapi.TransactionContextInterface, zoneCode string) (*Zone, error) {
+	key, err := zoneKey(ctx, zoneCode)
+	if err != nil {
+		return nil, err
+	}
+
+	zoneJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zone: %v", err)
+	}
+	if zoneJSON == nil {
+		return nil, fmt.Errorf("zone %s does not exist", zoneCode)
+	}
+
+	var zone Zone
+	err = json.Unmarshal(zoneJSON, &zone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &zone, nil
+}
+
+// GetAllZones returns the zoning registry
+func (c *PropertyContract) GetAllZones(ctx contractapi.TransactionContextInterface) ([]*Zone, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(zoneObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	zones := []*Zone{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var zone Zone
+		err = json.Unmarshal(queryResponse.Value, &zone)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, &zone)
+	}
+
+	return zones, nil
+}
+
+// validateLandUse checks that a property type is permitted in a zone
+func (c *PropertyContract) validateLandUse(ctx contractapi.TransactionContextInterface, zoneCode string, propertyType string) error {
+	if !isKnownPropertyType(propertyType) {
+		return fmt.Errorf("unknown property type %q", propertyType)
+	}
+
+	zone, err := c.GetZone(ctx, zoneCode)
+	if err != nil {
+		return err
+	}
+	if !zone.permits(propertyType) {
+		return fmt.Errorf("zone %s does not permit %s use; permitted uses are %v", zoneCode, propertyType, zone.PermittedUses)
+	}
+	return nil
+}
+
+// ChangeLandUse requests a change of a property's use and zone, e.g. converting
+// agricultural land to residential. It takes effect when approved with
+// ApproveLandUseChange. Only an owner may request it.
+func (c *PropertyContract) ChangeLandUse(ctx contractapi.TransactionContextInterface, propertyID string, requestID string, newPropertyType string, newZoneCode string, reason string) error {
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+
+	caller, err := requireOwner(ctx, property)
+	if err != nil {
+		return err
+	}
+	if property.Status == statusRetired {
+		return fmt.Errorf("property %s is %s", propertyID, property.Status)
+	}
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+
+	if requestID == "" || reason == "" {
+		return fmt.Errorf("request ID and reason are required")
+	}
+	if newZoneCode == "" {
+		newZoneCode = property.ZoneCode
+	}
+	if newZoneCode == "" {
+		return fmt.Errorf("property %s has no zone; a zone code is required", propertyID)
+	}
+	if newPropertyType == property.PropertyType && newZoneCode == property.ZoneCode {
+		return fmt.Errorf("property %s is already %s in zone %s", propertyID, newPropertyType, newZoneCode)
+	}
+	if err := c.validateLandUse(ctx, newZoneCode, newPropertyType); err != nil {
+		return err
+	}
+
+	changes, err := c.GetLandUseChanges(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if change.RequestID == requestID {
+			return fmt.Errorf("land-use change %s already exists on property %s", requestID, propertyID)
+		}
+		if change.Status == landUseChangePending {
+			return fmt.Errorf("property %s already has a pending land-use change %s", propertyID, change.RequestID)
+		}
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	change := &LandUseChange{
+		RequestID:    requestID,
+		PropertyID:   propertyID,
+		FromType:     property.PropertyType,
+		FromZoneCode: property.ZoneCode,
+		ToType:       newPropertyType,
+		ToZoneCode:   newZoneCode,
+		Reason:       reason,
+		Status:       landUseChangePending,
+		RequestedBy:  caller.ID,
+		RequestedAt:  time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)),
+	}
+
+	return putLandUseChange(ctx, change)
+}
+
+// ApproveLandUseChange approves a pending land-use change and applies it to the
+// property. The zone must still permit the new use. Registrar admin only.
+func (c *PropertyContract) ApproveLandUseChange(ctx contractapi.TransactionContextInterface, propertyID string, requestID string, comment string) error {
+	caller, change, err := c.decideLandUseChange(ctx, propertyID, requestID)
+	if err != nil {
+		return err
+	}
+
+	property, err := c.getProperty(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	if property.isOwner(caller.ID) {
+		return fmt.Errorf("access denied: caller %s cannot approve a land-use change to its own property %s", caller.ID, propertyID)
+	}
+	if err := requireNotFrozen(property); err != nil {
+		return err
+	}
+	if err := c.validateLandUse(ctx, change.ToZoneCode, change.ToType); err != nil {
+		return err
+	}
+
+	change.Status = landUseChangeApproved
+	change.DecisionComment = comment
+	if err := putLandUseChange(ctx, change); err != nil {
+		return err
+	}
+
+	property.PropertyType = change.ToType
+	property.ZoneCode = change.ToZoneCode
+	property.LastUpdated = change.DecidedAt
+
+	return c.putProperty(ctx, property)
+}
+
+// RejectLandUseChange turns down a pending land-use change with a reason.
+// Registrar admin only.
+func (c *PropertyContract) RejectLandUseChange(ctx contractapi.TransactionContextInterface, propertyID string, requestID string, reason string) error {
+	if reason == "" {
+		return fmt.Errorf("a rejection reason is required")
+	}
+
+	_, change, err := c.decideLandUseChange(ctx, propertyID, requestID)
+	if err != nil {
+		return err
+	}
+
+	change.Status = landUseChangeRejected
+	change.DecisionComment = reason
+
+	return putLandUseChange(ctx, change)
+}
+
+// GetLandUseChanges returns every land-use change requested for a property
+func (c *PropertyContract) GetLandUseChanges(ctx contractapi.TransactionContextInterface, propertyID string) ([]*LandUseChange, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(landUseChangeObjectType, []string{propertyID})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	changes := []*LandUseChange{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var change LandUseChange
+		err = json.Unmarshal(queryResponse.Value, &change)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, &change)
+	}
+
+	return changes, nil
+}
+
+// decideLandUseChange loads a pending land-use change for a registrar admin's
+// decision and stamps the decision
+func (c *PropertyContract) decideLandUseChange(ctx contractapi.TransactionContextInterface, propertyID string, requestID string) (*access.Caller, *LandUseChange, error) {
+	caller, err := access.RequireRegistrarAdmin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := landUseChangeKey(ctx, propertyID, requestID)
+	if err != nil {
+		return nil, nil, err
+	}
+	changeJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read land-use change: %v", err)
+	}
+	if changeJSON == nil {
+		return nil, nil, fmt.Errorf("land-use change %s does not exist on property %s", requestID, propertyID)
+	}
+
+	var change LandUseChange
+	err = json.Unmarshal(changeJSON, &change)
+	if err != nil {
+		return nil, nil, err
+	}
+	if change.Status != landUseChangePending {
+		return nil, nil, fmt.Errorf("land-use change %s is already %s", requestID, change.Status)
+	}
+
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	change.DecidedBy = caller.ID
+	change.DecidedAt = time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
+
+	return caller, &change, nil
+}
+
+// putLandUseChange writes a land-use change request under its composite key
+func putLandUseChange(ctx contractapi.TransactionContextInterface, change *LandUseChange) error {
+	key, err := landUseChangeKey(ctx, change.PropertyID, change.RequestID)
+	if err != nil {
+		return err
+	}
+
+	changeJSON, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, changeJSON)
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestZonePermits(t *testing.T) {
+	mixedUse := &Zone{ZoneCode: "MU-1", PermittedUses: []string{propertyTypeResidential, propertyTypeCommercial}}
+	agricultural := &Zone{ZoneCode: "AG-1", PermittedUses: []string{propertyTypeAgricultural}}
+	unzoned := &Zone{ZoneCode: "NONE"}
+
+	tests := []struct {
+		name         string
+		zone         *Zone
+		propertyType string
+		want         bool
+	}{
+		{"first permitted use", mixedUse, propertyTypeResidential, true},
+		{"second permitted use", mixedUse, propertyTypeCommercial, true},
+		{"use not permitted", mixedUse, propertyTypeIndustrial, false},
+		{"agricultural land", agricultural, propertyTypeAgricultural, true},
+		{"residential on agricultural land", agricultural, propertyTypeResidential, false},
+		{"case differs", mixedUse, "residential", false},
+		{"empty use", mixedUse, "", false},
+		{"zone permitting nothing", unzoned, propertyTypeResidential, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.zone.permits(tt.propertyType); got != tt.want {
+				t.Fatalf("zone %s permits(%q) = %v, want %v", tt.zone.ZoneCode, tt.propertyType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsKnownPropertyType(t *testing.T) {
+	tests := []struct {
+		propertyType string
+		want         bool
+	}{
+		{propertyTypeResidential, true},
+		{propertyTypeCommercial, true},
+		{propertyTypeAgricultural, true},
+		{propertyTypeIndustrial, true},
+		{"INSTITUTIONAL", false},
+		{"Residential", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isKnownPropertyType(tt.propertyType); got != tt.want {
+			t.Errorf("isKnownPropertyType(%q) = %v, want %v", tt.propertyType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import "testing"
+
+func TestUsersByRole(t *testing.T) {
+	queryString, err := usersByRole("SELLER")
+	if err != nil {
+		t.Fatalf("usersByRole: %v", err)
+	}
+	if want := `{"selector":{"role":"SELLER"}}`; queryString != want {
+		t.Fatalf("query = %s, want %s", queryString, want)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	"access"
+	"couchquery"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -13,6 +15,9 @@ type UserContract struct {
 	contractapi.Contract
 }
 
+// userObjectType namespaces user keys on the ledger
+const userObjectType = "user"
+
 // User represents a system user with credentials and documents stored on ledger
 type User struct {
 	UserID        string    `json:"userId"`
@@ -31,6 +36,13 @@ type User struct {
 	LastLogin     time.Time `json:"lastLogin"`
 }
 
+// PaginatedUserResult is one page of users plus the bookmark for the next page
+type PaginatedUserResult struct {
+	Records      []*User `json:"records"`
+	Bookmark     string  `json:"bookmark"`
+	FetchedCount int32   `json:"fetchedCount"`
+}
+
 // Document represents a user document stored on the ledger
 type Document struct {
 	DocumentID   string    `json:"documentId"`
@@ -57,7 +69,7 @@ func (c *UserContract) RegisterUser(ctx contractapi.TransactionContextInterface,
 	}
 	timestamp := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos))
 
-	user := User{
+	user := &User{
 		UserID:        userID,
 		Name:          name,
 		Email:         email,
@@ -74,17 +86,17 @@ func (c *UserContract) RegisterUser(ctx contractapi.TransactionContextInterface,
 		LastLogin:     timestamp,
 	}
 
-	userJSON, err := json.Marshal(user)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(userID, userJSON)
+	return c.putUser(ctx, user)
 }
 
 // GetUser retrieves a user from the ledger
 func (c *UserContract) GetUser(ctx contractapi.TransactionContextInterface, userID string) (*User, error) {
-	userJSON, err := ctx.GetStub().GetState(userID)
+	key, err := userKey(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	userJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read user: %v", err)
 	}
@@ -110,12 +122,7 @@ func (c *UserContract) UpdateUserVerification(ctx contractapi.TransactionContext
 
 	user.IsVerified = isVerified
 
-	userJSON, err := json.Marshal(user)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(userID, userJSON)
+	return c.putUser(ctx, user)
 }
 
 // AddDocument adds a document to a user's profile
@@ -142,12 +149,7 @@ func (c *UserContract) AddDocument(ctx contractapi.TransactionContextInterface,
 
 	user.Documents = append(user.Documents, document)
 
-	userJSON, err := json.Marshal(user)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(userID, userJSON)
+	return c.putUser(ctx, user)
 }
 
 // VerifyDocument marks a document as verified by an admin
@@ -171,12 +173,7 @@ func (c *UserContract) VerifyDocument(ctx contractapi.TransactionContextInterfac
 		return fmt.Errorf("document %s not found for user %s", documentID, userID)
 	}
 
-	userJSON, err := json.Marshal(user)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(userID, userJSON)
+	return c.putUser(ctx, user)
 }
 
 // UpdateLastLogin updates user's last login timestamp
@@ -194,32 +191,55 @@ func (c *UserContract) UpdateLastLogin(ctx contractapi.TransactionContextInterfa
 
 	user.LastLogin = timestamp
 
-	userJSON, err := json.Marshal(user)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(userID, userJSON)
+	return c.putUser(ctx, user)
 }
 
 // UserExists checks if a user exists
 func (c *UserContract) UserExists(ctx contractapi.TransactionContextInterface, userID string) (bool, error) {
-	userJSON, err := ctx.GetStub().GetState(userID)
+	key, err := userKey(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	userJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return false, fmt.Errorf("failed to read user: %v", err)
 	}
 	return userJSON != nil, nil
 }
 
+// userKey returns the composite ledger key of a user
+func userKey(ctx contractapi.TransactionContextInterface, userID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(userObjectType, []string{userID})
+}
+
+// putUser writes a user under its composite key
+func (c *UserContract) putUser(ctx contractapi.TransactionContextInterface, user *User) error {
+	key, err := userKey(ctx, user.UserID)
+	if err != nil {
+		return err
+	}
+
+	userJSON, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, userJSON)
+}
+
 // GetUsersByRole retrieves all users with a specific role
 func (c *UserContract) GetUsersByRole(ctx contractapi.TransactionContextInterface, role string) ([]*User, error) {
-	queryString := fmt.Sprintf(`{"selector":{"role":"%s"}}`, role)
+	queryString, err := usersByRole(role)
+	if err != nil {
+		return nil, err
+	}
 	return c.queryUsers(ctx, queryString)
 }
 
 // GetAllUsers retrieves all users
 func (c *UserContract) GetAllUsers(ctx contractapi.TransactionContextInterface) ([]*User, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(userObjectType, []string{})
 	if err != nil {
 		return nil, err
 	}
@@ -235,7 +255,7 @@ func (c *UserContract) GetAllUsers(ctx contractapi.TransactionContextInterface)
 		var user User
 		err = json.Unmarshal(queryResponse.Value, &user)
 		if err != nil {
-			continue
+			return nil, err
 		}
 		users = append(users, &user)
 	}
@@ -243,6 +263,92 @@ func (c *UserContract) GetAllUsers(ctx contractapi.TransactionContextInterface)
 	return users, nil
 }
 
+// GetUsersByRoleWithPagination retrieves one page of users with a specific role
+func (c *UserContract) GetUsersByRoleWithPagination(ctx contractapi.TransactionContextInterface, role string, pageSize int32, bookmark string) (*PaginatedUserResult, error) {
+	queryString, err := usersByRole(role)
+	if err != nil {
+		return nil, err
+	}
+	return c.queryUsersWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// GetAllUsersWithPagination retrieves one page of all users
+func (c *UserContract) GetAllUsersWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedUserResult, error) {
+	if err := couchquery.ValidatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(userObjectType, []string{}, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	users := []*User{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var user User
+		err = json.Unmarshal(queryResponse.Value, &user)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	return &PaginatedUserResult{
+		Records:      users,
+		Bookmark:     metadata.Bookmark,
+		FetchedCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+// queryUsersWithPagination - helper function for querying one page of users
+func (c *UserContract) queryUsersWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int32, bookmark string) (*PaginatedUserResult, error) {
+	if err := couchquery.ValidatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	users := []*User{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		objectType, _, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || objectType != userObjectType {
+			continue
+		}
+
+		var user User
+		err = json.Unmarshal(queryResponse.Value, &user)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	return &PaginatedUserResult{
+		Records:      users,
+		Bookmark:     metadata.Bookmark,
+		FetchedCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+// usersByRole builds the query for users with a role
+func usersByRole(role string) (string, error) {
+	return couchquery.Build(map[string]interface{}{"role": role})
+}
+
 // queryUsers - helper function for querying users
 func (c *UserContract) queryUsers(ctx contractapi.TransactionContextInterface, queryString string) ([]*User, error) {
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
@@ -257,11 +363,15 @@ func (c *UserContract) queryUsers(ctx contractapi.TransactionContextInterface, q
 		if err != nil {
 			return nil, err
 		}
+		objectType, _, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil || objectType != userObjectType {
+			continue
+		}
 
 		var user User
 		err = json.Unmarshal(queryResponse.Value, &user)
 		if err != nil {
-			continue
+			return nil, err
 		}
 		users = append(users, &user)
 	}
@@ -269,6 +379,46 @@ func (c *UserContract) queryUsers(ctx contractapi.TransactionContextInterface, q
 	return users, nil
 }
 
+// MigrateKeys rewrites users stored under plain user IDs to composite keys and
+// returns how many were moved. Running it again after a migration is a no-op.
+func (c *UserContract) MigrateKeys(ctx contractapi.TransactionContextInterface) (int, error) {
+	if _, err := access.RequireRegistrarAdmin(ctx); err != nil {
+		return 0, err
+	}
+
+	// GetStateByRange only returns plain keys, never composite ones
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return migrated, err
+		}
+
+		key, err := userKey(ctx, queryResponse.Key)
+		if err != nil {
+			return migrated, err
+		}
+
+		err = ctx.GetStub().PutState(key, queryResponse.Value)
+		if err != nil {
+			return migrated, err
+		}
+		err = ctx.GetStub().DelState(queryResponse.Key)
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
 func main() {
 	userChaincode, err := contractapi.NewChaincode(&UserContract{})
 	if err != nil {
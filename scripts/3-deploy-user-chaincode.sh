@@ -8,7 +8,8 @@ export CHAINCODE_NAME=user-contract
 export CHAINCODE_VERSION=1.0
 export SEQUENCE=1
 
-# Package
+# Package (vendor the shared access and couchquery modules so the package builds on its own)
+docker exec -w /opt/gopath/src/github.com/chaincode/user-contract cli go mod vendor
 docker exec cli peer lifecycle chaincode package ${CHAINCODE_NAME}.tar.gz \
   --path /opt/gopath/src/github.com/chaincode/user-contract \
   --lang golang \
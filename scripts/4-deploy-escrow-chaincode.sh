@@ -13,6 +13,8 @@ export SEQUENCE=2
 
 # Package chaincode
 echo "Step 1: Packaging chaincode..."
+# Vendor the shared access and couchquery modules so the package builds on its own
+docker exec -w /opt/gopath/src/github.com/chaincode/escrow-contract cli go mod vendor
 docker exec cli peer lifecycle chaincode package ${CHAINCODE_NAME}.tar.gz \
   --path /opt/gopath/src/github.com/chaincode/escrow-contract \
   --lang golang \
@@ -22,6 +22,8 @@ CC_SEQUENCE=1
 CC_SRC_PATH="../chaincode/offer-contract"
 
 echo "Step 1: Package chaincode"
+# Vendor the shared access and couchquery modules so the package builds on its own
+(cd ${CC_SRC_PATH} && go mod vendor)
 peer lifecycle chaincode package ${CC_NAME}.tar.gz \
     --path ${CC_SRC_PATH} \
     --lang golang \
@@ -1,10 +1,11 @@
 import { useState, useEffect } from 'react';
 import { Shield, CheckCircle, XCircle, Clock } from 'lucide-react';
 import { Button } from '@/components/ui/button';
+import { Input } from '@/components/ui/input';
 import { Card, CardContent, CardHeader, CardTitle } from '@/components/ui/card';
 import { Badge } from '@/components/ui/badge';
 import { useToast } from '@/hooks/use-toast';
-import { offerChaincode, propertyChaincode, sepoliaService } from '@/services/fabricClient';
+import { escrowChaincode, offerChaincode, propertyChaincode, sepoliaService } from '@/services/fabricClient';
 import {
   Table,
   TableBody,
@@ -28,9 +29,24 @@ interface Offer {
   sepoliaTxHash: string;
 }
 
+interface Escrow {
+  escrowId: string;
+  propertyId: string;
+  buyer: string;
+  seller: string;
+  amount: number;
+  status: string;
+}
+
+// Offer and escrow amounts are held in Wei
+const fromWei = (amount: number) => amount / 1e18;
+
 export const AdminDashboard = () => {
   const [pendingOffers, setPendingOffers] = useState<Offer[]>([]);
   const [processing, setProcessing] = useState<string | null>(null);
+  const [feePaymentRefs, setFeePaymentRefs] = useState<Record<string, string>>({});
+  // Sepolia transactions already sent per offer, so a retry never pays twice
+  const [sepoliaTxHashes, setSepoliaTxHashes] = useState<Record<string, string>>({});
   const [walletConnected, setWalletConnected] = useState(false);
   const { toast } = useToast();
 
@@ -44,67 +60,116 @@ export const AdminDashboard = () => {
     setWalletConnected(!!address);
   };
 
+  // Accepted offers await verification; verified offers whose settlement failed
+  // stay listed so the admin can retry it
   const loadPendingOffers = async () => {
     try {
-      const result = await offerChaincode.getPendingAdminVerifications();
-      if (result.status === 'SUCCESS') {
-        setPendingOffers(result.payload.data || []);
-      }
+      const [accepted, verified] = await Promise.all([
+        offerChaincode.getPendingAdminVerifications(),
+        offerChaincode.getOffersByStatus('ADMIN_VERIFIED'),
+      ]);
+      setPendingOffers([
+        ...(accepted.status === 'SUCCESS' ? accepted.payload.data || [] : []),
+        ...(verified.status === 'SUCCESS' ? verified.payload.data || [] : []),
+      ]);
     } catch (error) {
       console.error('Error loading offers:', error);
     }
   };
 
-  const handleVerifyOffer = async (offer: Offer) => {
+  // The buyer's funded escrow for an offer: same property, parties and amount
+  const findFundedEscrow = async (offer: Offer) => {
+    const result = await escrowChaincode.getEscrowsByProperty(offer.propertyId);
+    const escrows: Escrow[] = result.status === 'SUCCESS' ? result.payload.data || [] : [];
+    return escrows.find(
+      (escrow) =>
+        escrow.status === 'FUNDED' &&
+        escrow.propertyId === offer.propertyId &&
+        escrow.buyer === offer.buyerId &&
+        escrow.seller === offer.sellerId &&
+        escrow.amount === offer.offerAmount
+    );
+  };
+
+  // Duty and fees owed on the sale, in currency units
+  const estimateFees = async (offer: Offer) => {
+    const result = await propertyChaincode.estimateTransferFees(offer.propertyId, fromWei(offer.offerAmount));
+    if (result.status !== 'SUCCESS') {
+      throw new Error('Could not estimate the duty and fees owed on this sale');
+    }
+    return result.payload.data?.totalFees || 0;
+  };
+
+  // Sends the Sepolia record for an offer once; a retry reuses the hash
+  const recordOnSepolia = async (offer: Offer) => {
+    const recorded = offer.sepoliaTxHash || sepoliaTxHashes[offer.offerId];
+    if (recorded) {
+      return recorded;
+    }
+
     if (!walletConnected) {
-      toast({
-        title: 'Wallet Not Connected',
-        description: 'Please connect your wallet to verify transactions',
-        variant: 'destructive',
-      });
-      return;
+      throw new Error('Please connect your wallet to verify transactions');
+    }
+
+    // Switch to Sepolia network
+    const switched = await sepoliaService.switchToSepoliaTestnet();
+    if (!switched) {
+      throw new Error('Failed to switch to Sepolia network');
     }
 
+    // Record transaction on Sepolia
+    toast({
+      title: 'Recording Transaction',
+      description: 'Please confirm the transaction in MetaMask',
+    });
+
+    const txHash = await sepoliaService.recordTransaction(
+      offer.offerId,
+      offer.buyerId,
+      offer.sellerId,
+      fromWei(offer.offerAmount).toString()
+    );
+
+    if (!txHash) {
+      throw new Error('Transaction failed');
+    }
+    setSepoliaTxHashes((hashes) => ({ ...hashes, [offer.offerId]: txHash }));
+    return txHash;
+  };
+
+  const handleVerifyOffer = async (offer: Offer) => {
     setProcessing(offer.offerId);
     try {
-      // Switch to Sepolia network
-      const switched = await sepoliaService.switchToSepoliaTestnet();
-      if (!switched) {
-        throw new Error('Failed to switch to Sepolia network');
+      // Check everything the settlement needs before sending anything
+      const feePaymentRef = (feePaymentRefs[offer.offerId] || '').trim();
+      const totalFees = await estimateFees(offer);
+      if (totalFees > 0 && !feePaymentRef) {
+        throw new Error(`Enter the payment reference for the ₹${totalFees.toLocaleString()} duty and fees owed`);
       }
 
-      // Record transaction on Sepolia
-      toast({
-        title: 'Recording Transaction',
-        description: 'Please confirm the transaction in MetaMask',
-      });
-
-      const txHash = await sepoliaService.recordTransaction(
-        offer.offerId,
-        offer.buyerId,
-        offer.sellerId,
-        (offer.offerAmount / 1e18).toString() // Convert from Wei
-      );
-
-      if (!txHash) {
-        throw new Error('Transaction failed');
+      const escrow = await findFundedEscrow(offer);
+      if (!escrow) {
+        throw new Error('The buyer has not funded an escrow for this offer');
       }
 
-      // Update offer status on Hyperledger Fabric
-      const adminId = 'ADMIN_001'; // In production, get from logged-in user
-      await offerChaincode.adminVerifyOffer(offer.offerId, adminId, txHash);
+      // Steps already done by an earlier attempt are skipped
+      const txHash = await recordOnSepolia(offer);
+      if (offer.status === 'ACCEPTED') {
+        // The chaincode records the submitting admin as the verifier
+        await offerChaincode.adminVerifyOffer(offer.offerId, txHash);
+      }
 
-      // Transfer property ownership
-      await propertyChaincode.transferProperty(
-        offer.propertyId,
-        offer.buyerId,
-        offer.buyerName,
-        `TXN_${Date.now()}`
+      // Transfer title, complete the offer and release the escrow in one
+      // transaction, so a failure leaves none of them changed. The chaincode
+      // records the consideration in currency units.
+      await propertyChaincode.settleSale(
+        offer.offerId,
+        escrow.escrowId,
+        `TXN_${Date.now()}`,
+        txHash,
+        feePaymentRef
       );
 
-      // Complete the offer
-      await offerChaincode.completeOffer(offer.offerId);
-
       toast({
         title: 'Transaction Verified',
         description: `Transaction recorded on Sepolia: ${txHash.substring(0, 10)}...`,
@@ -119,6 +184,8 @@ export const AdminDashboard = () => {
         description: error.message || 'Failed to verify transaction',
         variant: 'destructive',
       });
+      // An offer verified before the failure is listed again for a retry
+      loadPendingOffers();
     } finally {
       setProcessing(null);
     }
@@ -225,6 +292,7 @@ export const AdminDashboard = () => {
                     <TableHead>Seller</TableHead>
                     <TableHead>Amount</TableHead>
                     <TableHead>Status</TableHead>
+                    <TableHead>Fee Payment Ref</TableHead>
                     <TableHead>Actions</TableHead>
                   </TableRow>
                 </TableHeader>
@@ -243,6 +311,17 @@ export const AdminDashboard = () => {
                       <TableCell>
                         <Badge variant="outline">{offer.status}</Badge>
                       </TableCell>
+                      <TableCell>
+                        <Input
+                          className="h-8 w-40"
+                          placeholder="Challan / receipt no."
+                          value={feePaymentRefs[offer.offerId] || ''}
+                          onChange={(e) =>
+                            setFeePaymentRefs((refs) => ({ ...refs, [offer.offerId]: e.target.value }))
+                          }
+                          disabled={processing === offer.offerId}
+                        />
+                      </TableCell>
                       <TableCell>
                         <div className="flex gap-2">
                           <Button
@@ -255,7 +334,7 @@ export const AdminDashboard = () => {
                             ) : (
                               <>
                                 <CheckCircle className="h-4 w-4 mr-1" />
-                                Verify
+                                {offer.status === 'ADMIN_VERIFIED' ? 'Settle' : 'Verify'}
                               </>
                             )}
                           </Button>
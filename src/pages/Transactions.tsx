@@ -1,68 +1,73 @@
-import { useState, useMemo } from "react";
+import { useState } from "react";
 import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
 import { Input } from "@/components/ui/input";
+import { Button } from "@/components/ui/button";
+import { Badge } from "@/components/ui/badge";
 import { Table, TableBody, TableCell, TableHead, TableHeader, TableRow } from "@/components/ui/table";
-import { Search, MapPin, Calendar, DollarSign } from "lucide-react";
-import transactionData from "@/data/tirupatidataset_with_location.json";
+import { Search, Calendar, DollarSign } from "lucide-react";
+import { useToast } from "@/hooks/use-toast";
+import { propertyChaincode } from "@/services/fabricClient";
 
+// A transfer record as returned by the property contract
 interface Transaction {
-  DISTRICT: string;
-  MANDAL: string;
-  VILLAGE: string;
-  SRO_CODE: number;
-  HABITATION: string;
-  WARD_NO: number;
-  BLOCK_NO: number;
-  DOOR_NO: number;
-  BI_NUMBER: string;
-  TR_DOOR_NO: string;
-  UNIT_RATE: number;
-  REV_UNIT_RATE: number;
-  PRE_REV_UNIT_RATE: number;
-  EFFECTIVE_DATE: string;
-  EX_EFFECTIVE_DATE: string;
-  TIME_STAMP: string;
-  REMARKS: string | null;
-  USERNAME: string;
-  COMM_RATE: number;
-  REV_COMM_RATE: number;
-  PRE_REV_COMM_RATE: number;
-  SIN_DEL: string | null;
-  REV_COMP_FLOOR1: number;
-  REV_COMP_FLOOR_OTH: number;
-  COMP_FLOOR1: number;
-  COMP_FLOOR_OTH: number;
-  PRE_COMP_FLOOR1: number;
-  PRE_COMP_FLOOR_OTH: number;
-  REV_TIMESTAMP: string | null;
-  BI_WARD: string | null;
-  BI_BLOCK: string | null;
-  ROWID: string;
+  transactionId: string;
+  propertyId: string;
+  transferType?: string;
+  fromOwner: string;
+  toOwner: string;
+  amount: number;
+  status: string;
+  share: number;
+  stampDuty?: number;
+  registrationFee?: number;
+  belowGuideline?: boolean;
+  timestamp: string;
 }
 
+type SearchMode = "property" | "party";
+
 export const Transactions = () => {
+  const [mode, setMode] = useState<SearchMode>("property");
   const [searchTerm, setSearchTerm] = useState("");
+  const [transactions, setTransactions] = useState<Transaction[]>([]);
+  const [searched, setSearched] = useState(false);
+  const [loading, setLoading] = useState(false);
   const [currentPage, setCurrentPage] = useState(1);
   const itemsPerPage = 50;
+  const { toast } = useToast();
 
-  const transactions: Transaction[] = transactionData.data;
+  // Transfers come from the ledger, oldest first, by property or by party
+  const loadTransactions = async () => {
+    const term = searchTerm.trim();
+    if (!term) {
+      return;
+    }
 
-  const filteredTransactions = useMemo(() => {
-    return transactions.filter((transaction) => {
-      const searchLower = searchTerm.toLowerCase();
-      return (
-        transaction.TR_DOOR_NO.toLowerCase().includes(searchLower) ||
-        transaction.VILLAGE.toLowerCase().includes(searchLower) ||
-        transaction.MANDAL.toLowerCase().includes(searchLower) ||
-        transaction.DISTRICT.toLowerCase().includes(searchLower)
-      );
-    });
-  }, [transactions, searchTerm]);
+    setLoading(true);
+    try {
+      const result =
+        mode === "property"
+          ? await propertyChaincode.getTransactionsByProperty(term)
+          : await propertyChaincode.getTransactionsByParty(term);
+      setTransactions(result.status === "SUCCESS" ? result.payload.data || [] : []);
+      setSearched(true);
+      setCurrentPage(1);
+    } catch (error: any) {
+      console.error("Error loading transactions:", error);
+      toast({
+        title: "Failed to Load Transactions",
+        description: error.message || "Could not query the ledger",
+        variant: "destructive",
+      });
+    } finally {
+      setLoading(false);
+    }
+  };
 
-  const totalPages = Math.ceil(filteredTransactions.length / itemsPerPage);
+  const totalPages = Math.max(1, Math.ceil(transactions.length / itemsPerPage));
   const startIndex = (currentPage - 1) * itemsPerPage;
   const endIndex = startIndex + itemsPerPage;
-  const currentTransactions = filteredTransactions.slice(startIndex, endIndex);
+  const currentTransactions = transactions.slice(startIndex, endIndex);
 
   const formatDate = (dateString: string) => {
     return new Date(dateString).toLocaleDateString('en-IN', {
@@ -89,78 +94,100 @@ export const Transactions = () => {
               Land Registry Transactions
             </CardTitle>
             <p className="text-muted-foreground">
-              Browse {transactions.length.toLocaleString()} property records from Tirupati
+              Transfers recorded on the ledger for a property or a party
             </p>
           </CardHeader>
           <CardContent className="space-y-6">
-            <div className="relative">
-              <Search className="absolute left-3 top-3 h-4 w-4 text-muted-foreground" />
-              <Input
-                placeholder="Search by door number, village, mandal, or district..."
-                value={searchTerm}
-                onChange={(e) => {
-                  setSearchTerm(e.target.value);
-                  setCurrentPage(1);
-                }}
-                className="pl-10"
-              />
+            <div className="flex gap-2">
+              <Button
+                variant={mode === "property" ? "default" : "outline"}
+                onClick={() => setMode("property")}
+              >
+                By Property
+              </Button>
+              <Button
+                variant={mode === "party" ? "default" : "outline"}
+                onClick={() => setMode("party")}
+              >
+                By Party
+              </Button>
+              <div className="relative flex-1">
+                <Search className="absolute left-3 top-3 h-4 w-4 text-muted-foreground" />
+                <Input
+                  placeholder={mode === "property" ? "Property ID" : "User ID (MSPID::enrollmentID)"}
+                  value={searchTerm}
+                  onChange={(e) => setSearchTerm(e.target.value)}
+                  onKeyDown={(e) => e.key === "Enter" && loadTransactions()}
+                  className="pl-10"
+                />
+              </div>
+              <Button onClick={loadTransactions} disabled={loading || !searchTerm.trim()}>
+                {loading ? "Searching..." : "Search"}
+              </Button>
             </div>
 
             <div className="rounded-lg border">
               <Table>
                 <TableHeader>
                   <TableRow>
-                    <TableHead>Door No</TableHead>
-                    <TableHead>Location</TableHead>
-                    <TableHead>Unit Rate</TableHead>
-                    <TableHead>Commercial Rate</TableHead>
-                    <TableHead>Effective Date</TableHead>
-                    <TableHead>Updated By</TableHead>
+                    <TableHead>Transaction ID</TableHead>
+                    <TableHead>Property ID</TableHead>
+                    <TableHead>Type</TableHead>
+                    <TableHead>From</TableHead>
+                    <TableHead>To</TableHead>
+                    <TableHead>Consideration</TableHead>
+                    <TableHead>Duty &amp; Fees</TableHead>
+                    <TableHead>Date</TableHead>
                   </TableRow>
                 </TableHeader>
                 <TableBody>
                   {currentTransactions.map((transaction) => (
-                    <TableRow key={transaction.ROWID}>
-                      <TableCell className="font-medium">
-                        {transaction.TR_DOOR_NO}
-                      </TableCell>
+                    <TableRow key={transaction.transactionId}>
+                      <TableCell className="font-mono text-xs">{transaction.transactionId}</TableCell>
+                      <TableCell className="font-mono text-xs">{transaction.propertyId}</TableCell>
                       <TableCell>
-                        <div className="flex items-start gap-2">
-                          <MapPin className="h-4 w-4 text-primary mt-0.5" />
-                          <div>
-                            <div className="font-medium">{transaction.VILLAGE}</div>
-                            <div className="text-sm text-muted-foreground">
-                              {transaction.MANDAL}, {transaction.DISTRICT}
-                            </div>
-                          </div>
+                        <div className="flex items-center gap-2">
+                          <Badge variant="outline">{transaction.transferType || "SALE"}</Badge>
+                          {transaction.share < 100 && (
+                            <span className="text-xs text-muted-foreground">{transaction.share}%</span>
+                          )}
                         </div>
                       </TableCell>
+                      <TableCell className="text-sm">{transaction.fromOwner}</TableCell>
+                      <TableCell className="text-sm">{transaction.toOwner}</TableCell>
                       <TableCell>
                         <div className="flex items-center gap-2">
                           <DollarSign className="h-4 w-4 text-green-600" />
-                          <span className="font-semibold">{formatCurrency(transaction.UNIT_RATE)}</span>
+                          <span className="font-semibold">{formatCurrency(transaction.amount)}</span>
+                          {transaction.belowGuideline && <Badge variant="destructive">Below guideline</Badge>}
                         </div>
                       </TableCell>
-                      <TableCell>{formatCurrency(transaction.COMM_RATE)}</TableCell>
+                      <TableCell>
+                        {formatCurrency((transaction.stampDuty || 0) + (transaction.registrationFee || 0))}
+                      </TableCell>
                       <TableCell>
                         <div className="flex items-center gap-2">
                           <Calendar className="h-4 w-4 text-muted-foreground" />
-                          <span className="text-sm">{formatDate(transaction.EFFECTIVE_DATE)}</span>
+                          <span className="text-sm">{formatDate(transaction.timestamp)}</span>
                         </div>
                       </TableCell>
-                      <TableCell className="text-sm text-muted-foreground">
-                        {transaction.USERNAME}
-                      </TableCell>
                     </TableRow>
                   ))}
+                  {searched && transactions.length === 0 && (
+                    <TableRow>
+                      <TableCell colSpan={8} className="text-center text-muted-foreground">
+                        No transactions recorded
+                      </TableCell>
+                    </TableRow>
+                  )}
                 </TableBody>
               </Table>
             </div>
 
             <div className="flex items-center justify-between">
               <p className="text-sm text-muted-foreground">
-                Showing {startIndex + 1} to {Math.min(endIndex, filteredTransactions.length)} of{" "}
-                {filteredTransactions.length} transactions
+                Showing {transactions.length === 0 ? 0 : startIndex + 1} to {Math.min(endIndex, transactions.length)} of{" "}
+                {transactions.length} transactions
               </p>
               <div className="flex gap-2">
                 <button
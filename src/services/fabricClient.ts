@@ -78,6 +78,10 @@ const fabricConfig: FabricConfig = {
 
 export const fabricClient = new FabricClient(fabricConfig);
 
+// Property-contract user IDs (owners, heirs, tenants, claimants) are qualified with the MSP ID
+export const identityId = (enrollmentId: string, mspId: string = fabricConfig.mspId) =>
+  `${mspId}::${enrollmentId}`;
+
 // User chaincode functions
 export const userChaincode = {
   // Register new user on blockchain with credentials and documents
@@ -153,9 +157,11 @@ export const propertyChaincode = {
     area: number;
     price: number;
     propertyType: string;
+    zoneCode: string;
     description: string;
     latitude: number;
     longitude: number;
+    boundary?: { type: 'Polygon'; coordinates: number[][][] };
   }) {
     return fabricClient.invokeChaincode('property-contract', 'RegisterProperty', [
       propertyData.propertyId,
@@ -165,36 +171,387 @@ export const propertyChaincode = {
       propertyData.area.toString(),
       propertyData.price.toString(),
       propertyData.propertyType,
+      propertyData.zoneCode,
       propertyData.description,
       propertyData.latitude.toString(),
-      propertyData.longitude.toString()
+      propertyData.longitude.toString(),
+      propertyData.boundary ? JSON.stringify(propertyData.boundary) : ''
+    ]);
+  },
+
+  // Create or update a zone: permitted land uses and maximum floor area ratio (Admin only)
+  async setZone(zoneCode: string, name: string, permittedUses: string[], maxFloorAreaRatio: number) {
+    return fabricClient.invokeChaincode('property-contract', 'SetZone', [
+      zoneCode,
+      name,
+      JSON.stringify(permittedUses),
+      maxFloorAreaRatio.toString()
     ]);
   },
 
-  // Verify property (Admin only)
-  async verifyProperty(propertyId: string, verifierId: string) {
-    return fabricClient.invokeChaincode('property-contract', 'VerifyProperty', [
+  // Get a zone of the zoning registry
+  async getZone(zoneCode: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetZone', [zoneCode]);
+  },
+
+  // Get the zoning registry
+  async getAllZones() {
+    return fabricClient.queryChaincode('property-contract', 'GetAllZones', []);
+  },
+
+  // Request a change of a property's land use and zone (owner); an empty zone code keeps the current zone
+  async changeLandUse(propertyId: string, requestId: string, newPropertyType: string, newZoneCode: string, reason: string) {
+    return fabricClient.invokeChaincode('property-contract', 'ChangeLandUse', [
       propertyId,
-      verifierId
+      requestId,
+      newPropertyType,
+      newZoneCode,
+      reason
+    ]);
+  },
+
+  // Approve a pending land-use change (Admin only)
+  async approveLandUseChange(propertyId: string, requestId: string, comment: string = '') {
+    return fabricClient.invokeChaincode('property-contract', 'ApproveLandUseChange', [propertyId, requestId, comment]);
+  },
+
+  // Reject a pending land-use change with a reason (Admin only)
+  async rejectLandUseChange(propertyId: string, requestId: string, reason: string) {
+    return fabricClient.invokeChaincode('property-contract', 'RejectLandUseChange', [propertyId, requestId, reason]);
+  },
+
+  // Get the land-use changes requested for a property
+  async getLandUseChanges(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetLandUseChanges', [propertyId]);
+  },
+
+  // Approve a pending registration (verifier/admin of an accepted org; verifier is taken from the caller identity).
+  // The property becomes VERIFIED once the verification policy's approvals are met.
+  async verifyProperty(propertyId: string, comment: string = '') {
+    return fabricClient.invokeChaincode('property-contract', 'VerifyProperty', [propertyId, comment]);
+  },
+
+  // Send a pending registration back to its owner for changes
+  async requestChanges(propertyId: string, comment: string) {
+    return fabricClient.invokeChaincode('property-contract', 'RequestChanges', [propertyId, comment]);
+  },
+
+  // Reject a registration with a reason
+  async rejectProperty(propertyId: string, reason: string) {
+    return fabricClient.invokeChaincode('property-contract', 'RejectProperty', [propertyId, reason]);
+  },
+
+  // Resubmit a registration after changes or rejection (owner only)
+  async resubmitProperty(propertyId: string, comment: string = '') {
+    return fabricClient.invokeChaincode('property-contract', 'ResubmitProperty', [propertyId, comment]);
+  },
+
+  // Add a reviewer comment to a registration under review
+  async addReviewComment(propertyId: string, comment: string) {
+    return fabricClient.invokeChaincode('property-contract', 'AddReviewComment', [propertyId, comment]);
+  },
+
+  // Get the approval rule for verifications
+  async getVerificationPolicy() {
+    return fabricClient.queryChaincode('property-contract', 'GetVerificationPolicy', []);
+  },
+
+  // Set the approval rule, e.g. two verifiers from distinct orgs (Admin only)
+  async setVerificationPolicy(requiredApprovals: number, requireDistinctOrgs: boolean, verifierMspIds: string[]) {
+    return fabricClient.invokeChaincode('property-contract', 'SetVerificationPolicy', [
+      requiredApprovals.toString(),
+      requireDistinctOrgs.toString(),
+      JSON.stringify(verifierMspIds)
     ]);
   },
 
-  // Transfer property ownership
-  async transferProperty(propertyId: string, newOwner: string, newOwnerName: string, transactionId: string) {
+  // Transfer property ownership for the consideration actually paid; feePaymentRef is required when
+  // stamp duty or fees are owed. SALE and GIFT are made by an owner; COURT_ORDER and
+  // GOVERNMENT_ACQUISITION by an admin against legalReference
+  async transferProperty(
+    propertyId: string,
+    newOwner: string,
+    newOwnerName: string,
+    transactionId: string,
+    transferType: 'SALE' | 'GIFT' | 'COURT_ORDER' | 'GOVERNMENT_ACQUISITION',
+    consideration: number,
+    legalReference: string = '',
+    feePaymentRef: string = ''
+  ) {
     return fabricClient.invokeChaincode('property-contract', 'TransferProperty', [
       propertyId,
       newOwner,
       newOwnerName,
-      transactionId
+      transactionId,
+      transferType,
+      consideration.toString(),
+      legalReference,
+      feePaymentRef
+    ]);
+  },
+
+  // Settle a sale: transfer title, complete the offer and release escrow in one transaction (Admin only)
+  async settleSale(offerId: string, escrowId: string, transactionId: string, releaseTxHash: string, feePaymentRef: string = '') {
+    return fabricClient.invokeChaincode('property-contract', 'SettleSale', [
+      offerId,
+      escrowId,
+      transactionId,
+      releaseTxHash,
+      feePaymentRef
+    ]);
+  },
+
+  // Pass a deceased owner's share to heirs under a succession certificate or will (Registrar only)
+  async successionTransfer(
+    propertyId: string,
+    transactionId: string,
+    deceasedOwnerId: string,
+    heirs: { ownerId: string; ownerName: string; share: number }[],
+    instrumentType: string,
+    documentHash: string,
+    authorityReference: string
+  ) {
+    return fabricClient.invokeChaincode('property-contract', 'SuccessionTransfer', [
+      propertyId,
+      transactionId,
+      deceasedOwnerId,
+      JSON.stringify(heirs),
+      instrumentType,
+      documentHash,
+      authorityReference
+    ]);
+  },
+
+  // Publish a new version of the stamp duty and registration fee schedule (Admin only)
+  async publishFeeSchedule(schedule: {
+    rates: {
+      propertyType: string;
+      locationTier: string;
+      stampDutyRate: number;
+      registrationFeeRate: number;
+      minRegistrationFee: number;
+      maxRegistrationFee: number;
+    }[];
+    locationTiers: { location: string; tier: string }[];
+  }) {
+    return fabricClient.invokeChaincode('property-contract', 'PublishFeeSchedule', [JSON.stringify(schedule)]);
+  },
+
+  // Get the fee schedule in force
+  async getFeeSchedule() {
+    return fabricClient.queryChaincode('property-contract', 'GetFeeSchedule', []);
+  },
+
+  // Get a published version of the fee schedule
+  async getFeeScheduleVersion(version: number) {
+    return fabricClient.queryChaincode('property-contract', 'GetFeeScheduleVersion', [version.toString()]);
+  },
+
+  // Estimate the stamp duty and registration fee owed on transferring a property for an amount
+  async estimateTransferFees(propertyId: string, amount: number) {
+    return fabricClient.queryChaincode('property-contract', 'EstimateTransferFees', [propertyId, amount.toString()]);
+  },
+
+  // Publish the guideline (circle) rate per area unit for a locality and property type (Admin only)
+  async setGuidelineRate(locality: string, propertyType: string, ratePerArea: number, effectiveFrom: string) {
+    return fabricClient.invokeChaincode('property-contract', 'SetGuidelineRate', [
+      locality,
+      propertyType,
+      ratePerArea.toString(),
+      effectiveFrom
+    ]);
+  },
+
+  // Get the revisions of a guideline rate, oldest first
+  async getGuidelineRates(locality: string, propertyType: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetGuidelineRates', [locality, propertyType]);
+  },
+
+  // Get a property's guideline value from its area and the rate in force
+  async getGuidelineValue(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetGuidelineValue', [propertyId]);
+  },
+
+  // Check whether an amount is below a property's guideline value
+  async checkGuidelineValue(propertyId: string, amount: number) {
+    return fabricClient.queryChaincode('property-contract', 'CheckGuidelineValue', [propertyId, amount.toString()]);
+  },
+
+  // Get transfers recorded below the guideline value (Admin review)
+  async getBelowGuidelineTransactions() {
+    return fabricClient.queryChaincode('property-contract', 'GetBelowGuidelineTransactions', []);
+  },
+
+  // Record joint owners and their percentage shares (Admin only)
+  async registerCoOwnership(propertyId: string, owners: { ownerId: string; ownerName: string; share: number }[]) {
+    return fabricClient.invokeChaincode('property-contract', 'RegisterCoOwnership', [propertyId, JSON.stringify(owners)]);
+  },
+
+  // Sell or gift part of the caller's share to another user; feePaymentRef is required when
+  // stamp duty or fees are owed on the share
+  async transferShare(
+    propertyId: string,
+    toOwnerId: string,
+    toOwnerName: string,
+    share: number,
+    transactionId: string,
+    transferType: 'SALE' | 'GIFT',
+    consideration: number,
+    feePaymentRef?: string
+  ) {
+    return fabricClient.invokeChaincode('property-contract', 'TransferShare', [
+      propertyId,
+      toOwnerId,
+      toOwnerName,
+      share.toString(),
+      transactionId,
+      transferType,
+      consideration.toString(),
+      feePaymentRef || ''
+    ]);
+  },
+
+  // Consent, as the calling co-owner, to selling the property to a buyer
+  async grantSaleConsent(propertyId: string, buyerId: string) {
+    return fabricClient.invokeChaincode('property-contract', 'GrantSaleConsent', [propertyId, buyerId]);
+  },
+
+  // Withdraw the calling co-owner's sale consent
+  async revokeSaleConsent(propertyId: string) {
+    return fabricClient.invokeChaincode('property-contract', 'RevokeSaleConsent', [propertyId]);
+  },
+
+  // Check whether every co-owner has consented to selling to a buyer
+  async hasSaleConsent(propertyId: string, buyerId: string) {
+    return fabricClient.queryChaincode('property-contract', 'HasSaleConsent', [propertyId, buyerId]);
+  },
+
+  // Split a parcel into children whose areas sum to the parent's (Admin only)
+  async subdivideProperty(parentId: string, children: {
+    propertyId: string;
+    area: number;
+    price: number;
+    location?: string;
+    description?: string;
+    latitude: number;
+    longitude: number;
+    boundary?: { type: 'Polygon'; coordinates: number[][][] };
+  }[]) {
+    return fabricClient.invokeChaincode('property-contract', 'SubdivideProperty', [parentId, JSON.stringify(children)]);
+  },
+
+  // Merge parcels with the same owners into a new parcel (Admin only)
+  async mergeProperties(propertyIds: string[], newId: string, boundary?: { type: 'Polygon'; coordinates: number[][][] }) {
+    return fabricClient.invokeChaincode('property-contract', 'MergeProperties', [
+      JSON.stringify(propertyIds),
+      newId,
+      boundary ? JSON.stringify(boundary) : ''
+    ]);
+  },
+
+  // Get the parcels a property was split or merged from
+  async getParentProperties(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetParentProperties', [propertyId]);
+  },
+
+  // Get the parcels that replaced a retired property
+  async getChildProperties(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetChildProperties', [propertyId]);
+  },
+
+  // Get the organizations allowed to register encumbrances
+  async getEncumbranceHolders() {
+    return fabricClient.queryChaincode('property-contract', 'GetEncumbranceHolders', []);
+  },
+
+  // Set the lender and court organizations allowed to register encumbrances (Admin only)
+  async setEncumbranceHolders(holderMspIds: string[]) {
+    return fabricClient.invokeChaincode('property-contract', 'SetEncumbranceHolders', [JSON.stringify(holderMspIds)]);
+  },
+
+  // Register a mortgage or lien held by the caller's organization, which must be a listed holder; dates are ISO 8601
+  async addEncumbrance(propertyId: string, encumbranceId: string, type: 'MORTGAGE' | 'LIEN', amount: number, startDate: string, endDate?: string) {
+    return fabricClient.invokeChaincode('property-contract', 'AddEncumbrance', [
+      propertyId,
+      encumbranceId,
+      type,
+      amount.toString(),
+      startDate,
+      endDate || ''
+    ]);
+  },
+
+  // Discharge an encumbrance (holder organization only)
+  async dischargeEncumbrance(propertyId: string, encumbranceId: string) {
+    return fabricClient.invokeChaincode('property-contract', 'DischargeEncumbrance', [propertyId, encumbranceId]);
+  },
+
+  // Get the encumbrances registered against a property
+  async getEncumbrances(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetEncumbrances', [propertyId]);
+  },
+
+  // Register a lease on a property or one of its units; dates are ISO 8601
+  async createLease(propertyId: string, leaseId: string, lease: {
+    unitId?: string;
+    tenantId: string;
+    tenantName: string;
+    monthlyRent: number;
+    deposit: number;
+    startDate: string;
+    endDate: string;
+    renewalTerms?: string;
+  }) {
+    return fabricClient.invokeChaincode('property-contract', 'CreateLease', [
+      propertyId,
+      leaseId,
+      lease.unitId || '',
+      lease.tenantId,
+      lease.tenantName,
+      lease.monthlyRent.toString(),
+      lease.deposit.toString(),
+      lease.startDate,
+      lease.endDate,
+      lease.renewalTerms || ''
     ]);
   },
 
+  // Extend a lease to a new end date and rent
+  async renewLease(propertyId: string, leaseId: string, endDate: string, monthlyRent: number) {
+    return fabricClient.invokeChaincode('property-contract', 'RenewLease', [propertyId, leaseId, endDate, monthlyRent.toString()]);
+  },
+
+  // Terminate a lease (owner or tenant)
+  async terminateLease(propertyId: string, leaseId: string, reason: string) {
+    return fabricClient.invokeChaincode('property-contract', 'TerminateLease', [propertyId, leaseId, reason]);
+  },
+
+  // Get every lease of a property
+  async getLeases(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetLeases', [propertyId]);
+  },
+
+  // Get the lease in force now
+  async getActiveLease(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetActiveLease', [propertyId]);
+  },
+
+  // Get every lease in force now, one per let unit
+  async getActiveLeases(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetActiveLeases', [propertyId]);
+  },
+
+  // Check whether a property can receive offers
+  async getSaleEligibility(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetSaleEligibility', [propertyId]);
+  },
+
   // Get property details
   async getProperty(propertyId: string) {
     return fabricClient.queryChaincode('property-contract', 'GetProperty', [propertyId]);
   },
 
-  // Get properties by owner
+  // Get properties the user owns or holds a share of
   async getPropertiesByOwner(owner: string) {
     return fabricClient.queryChaincode('property-contract', 'GetPropertiesByOwner', [owner]);
   },
@@ -204,6 +561,135 @@ export const propertyChaincode = {
     return fabricClient.queryChaincode('property-contract', 'GetPropertiesByStatus', [status]);
   },
 
+  // Get properties within radiusKm of a point, nearest first
+  async getPropertiesNear(latitude: number, longitude: number, radiusKm: number) {
+    return fabricClient.queryChaincode('property-contract', 'GetPropertiesNear', [
+      latitude.toString(),
+      longitude.toString(),
+      radiusKm.toString()
+    ]);
+  },
+
+  // Get properties inside a map viewport
+  async getPropertiesInBoundingBox(minLatitude: number, minLongitude: number, maxLatitude: number, maxLongitude: number) {
+    return fabricClient.queryChaincode('property-contract', 'GetPropertiesInBoundingBox', [
+      minLatitude.toString(),
+      minLongitude.toString(),
+      maxLatitude.toString(),
+      maxLongitude.toString()
+    ]);
+  },
+
+  // Register a document's SHA-256 (hex) and off-chain location
+  async addPropertyDocument(propertyId: string, documentId: string, documentType: string, documentHash: string, uri: string) {
+    return fabricClient.invokeChaincode('property-contract', 'AddPropertyDocument', [
+      propertyId,
+      documentId,
+      documentType,
+      documentHash,
+      uri
+    ]);
+  },
+
+  // Mark a document as verified (registrar only)
+  async verifyPropertyDocument(propertyId: string, documentId: string) {
+    return fabricClient.invokeChaincode('property-contract', 'VerifyPropertyDocument', [propertyId, documentId]);
+  },
+
+  // Revoke a document (uploader or Admin)
+  async revokePropertyDocument(propertyId: string, documentId: string, reason: string) {
+    return fabricClient.invokeChaincode('property-contract', 'RevokePropertyDocument', [propertyId, documentId, reason]);
+  },
+
+  // Get the documents of a property
+  async getPropertyDocuments(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetPropertyDocuments', [propertyId]);
+  },
+
+  // Check a file's SHA-256 (hex) against the documents registered for a property
+  async verifyDocumentHash(propertyId: string, documentHash: string) {
+    return fabricClient.queryChaincode('property-contract', 'VerifyDocumentHash', [propertyId, documentHash]);
+  },
+
+  // Raise a dispute over a property's title with the SHA-256 (hex) of the evidence
+  async raiseDispute(propertyId: string, disputeId: string, claimant: string, evidenceHash: string, description: string) {
+    return fabricClient.invokeChaincode('property-contract', 'RaiseDispute', [
+      propertyId,
+      disputeId,
+      claimant,
+      evidenceHash,
+      description
+    ]);
+  },
+
+  // Freeze a property for an open dispute under a court order or registrar decision (Admin only)
+  async freezeProperty(propertyId: string, disputeId: string, courtReference: string) {
+    return fabricClient.invokeChaincode('property-contract', 'FreezeProperty', [propertyId, disputeId, courtReference]);
+  },
+
+  // Resolve a dispute as UPHELD, DISMISSED or SETTLED, lifting its freeze (Admin only)
+  async resolveDispute(propertyId: string, disputeId: string, outcome: 'UPHELD' | 'DISMISSED' | 'SETTLED', comment: string) {
+    return fabricClient.invokeChaincode('property-contract', 'ResolveDispute', [propertyId, disputeId, outcome, comment]);
+  },
+
+  // Get the disputes raised against a property
+  async getDisputesByProperty(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetDisputesByProperty', [propertyId]);
+  },
+
+  // Get the disputes a user raised or was named in
+  async getDisputesByParty(partyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetDisputesByParty', [partyId]);
+  },
+
+  // Get a property's price changes, oldest first
+  async getPriceHistory(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetPriceHistory', [propertyId]);
+  },
+
+  // Get median/min/max price per area unit of verified properties by type and location
+  async getPriceStatistics(propertyType: string = '', location: string = '') {
+    return fabricClient.queryChaincode('property-contract', 'GetPriceStatistics', [propertyType, location]);
+  },
+
+  // Count a property view; views are recorded apart from the property so they never conflict with updates
+  async incrementPropertyViews(propertyId: string) {
+    return fabricClient.invokeChaincode('property-contract', 'IncrementPropertyViews', [propertyId]);
+  },
+
+  // Fold recorded views into the property's view counter (Admin only)
+  async compactPropertyViews(propertyId: string) {
+    return fabricClient.invokeChaincode('property-contract', 'CompactPropertyViews', [propertyId]);
+  },
+
+  // Fold recorded views of every property into their counters, which lists report (Admin only)
+  async compactAllPropertyViews() {
+    return fabricClient.invokeChaincode('property-contract', 'CompactAllPropertyViews', []);
+  },
+
+  // Stamp docType (and registeredAtKey on properties, recordedAt on transactions) on records stored before they existed, so rich queries find them (Admin only)
+  async backfillDocTypes() {
+    return fabricClient.invokeChaincode('property-contract', 'BackfillDocTypes', []);
+  },
+
+  // Qualify bare owner IDs as MSPID::enrollmentID from a mapping, else with a default MSP ID (Admin only)
+  async qualifyOwnerIDs(defaultMspId: string, mapping: Record<string, string> = {}) {
+    return fabricClient.invokeChaincode('property-contract', 'QualifyOwnerIDs', [
+      defaultMspId,
+      JSON.stringify(mapping)
+    ]);
+  },
+
+  // Get the title report (encumbrance certificate) between two ISO 8601 dates
+  async getTitleReport(propertyId: string, fromDate: string, toDate: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetTitleReport', [propertyId, fromDate, toDate]);
+  },
+
+  // Check a title report's hash against the ledger
+  async verifyTitleReport(report: object) {
+    return fabricClient.queryChaincode('property-contract', 'VerifyTitleReport', [JSON.stringify(report)]);
+  },
+
   // Get all properties
   async getAllProperties() {
     return fabricClient.queryChaincode('property-contract', 'GetAllProperties', []);
@@ -214,7 +700,28 @@ export const propertyChaincode = {
     return fabricClient.queryChaincode('property-contract', 'GetPropertyHistory', [propertyId]);
   },
 
-  // Update property status
+  // Get a transfer record
+  async getTransaction(transactionId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetTransaction', [transactionId]);
+  },
+
+  // Get the transfers of a property, oldest first
+  async getTransactionsByProperty(propertyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetTransactionsByProperty', [propertyId]);
+  },
+
+  // Get the transfers a user sold, bought or inherited under, oldest first
+  async getTransactionsByParty(partyId: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetTransactionsByParty', [partyId]);
+  },
+
+  // Get the transfers recorded between two ISO 8601 dates, inclusive
+  async getTransactionsByDateRange(fromDate: string, toDate: string) {
+    return fabricClient.queryChaincode('property-contract', 'GetTransactionsByDateRange', [fromDate, toDate]);
+  },
+
+  // Update property status; UNDER_CONTRACT is entered by accepting an offer and cannot be
+  // left while an accepted offer or funded escrow remains
   async updatePropertyStatus(propertyId: string, status: string) {
     return fabricClient.invokeChaincode('property-contract', 'UpdatePropertyStatus', [
       propertyId,
@@ -258,16 +765,15 @@ export const offerChaincode = {
     return fabricClient.invokeChaincode('offer-contract', 'RejectOffer', [offerId]);
   },
 
-  // Admin verify offer and record Sepolia transaction
-  async adminVerifyOffer(offerId: string, adminId: string, sepoliaTxHash: string) {
+  // Admin verify offer and record Sepolia transaction (Admin only; the submitting admin is recorded)
+  async adminVerifyOffer(offerId: string, sepoliaTxHash: string) {
     return fabricClient.invokeChaincode('offer-contract', 'AdminVerifyOffer', [
       offerId,
-      adminId,
       sepoliaTxHash
     ]);
   },
 
-  // Complete offer after land transfer
+  // Complete offer after land transfer (Admin only; settleSale does this as part of a sale)
   async completeOffer(offerId: string) {
     return fabricClient.invokeChaincode('offer-contract', 'CompleteOffer', [offerId]);
   },
@@ -307,6 +813,11 @@ export const offerChaincode = {
     return fabricClient.queryChaincode('offer-contract', 'GetPendingAdminVerifications', []);
   },
 
+  // Get open offers made below the property's guideline value (Admin review)
+  async getBelowGuidelineOffers() {
+    return fabricClient.queryChaincode('offer-contract', 'GetBelowGuidelineOffers', []);
+  },
+
   // Get offer history
   async getOfferHistory(offerId: string) {
     return fabricClient.queryChaincode('offer-contract', 'GetOfferHistory', [offerId]);
@@ -340,7 +851,7 @@ export const escrowChaincode = {
     ]);
   },
 
-  // Release escrow funds
+  // Release escrow funds (Admin only; settleSale does this as part of a sale)
   async releaseEscrow(escrowId: string, releaseTxHash: string) {
     return fabricClient.invokeChaincode('escrow-contract', 'ReleaseEscrow', [
       escrowId,
@@ -364,6 +875,11 @@ export const escrowChaincode = {
   // Get all escrows
   async getAllEscrows() {
     return fabricClient.queryChaincode('escrow-contract', 'GetAllEscrows', []);
+  },
+
+  // Get the escrows opened for a property
+  async getEscrowsByProperty(propertyId: string) {
+    return fabricClient.queryChaincode('escrow-contract', 'GetEscrowsByProperty', [propertyId]);
   }
 };
 